@@ -1,12 +1,14 @@
 package main
 
 // USAGE:
-// protoc --plugin ./protoc-gen-k8s --k8s_out=. --k8s_opt=group=drekle.example.io examples/gcp.proto
+// protoc --plugin ./protoc-gen-k8s --k8s_out=. --k8s_opt=group=drekle.example.io,module=github.com/example/controller examples/gcp.proto
+//
+// The supported options are listed with --k8s_opt=help
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
 
 	"github.com/drekle/protoc-gen-k8s/pkg/generator"
 	"github.com/gogo/protobuf/proto"
@@ -15,42 +17,57 @@ import (
 
 func main() {
 	req := &plugin.CodeGeneratorRequest{}
-	resp := &plugin.CodeGeneratorResponse{}
+	resp := &plugin.CodeGeneratorResponse{
+		// supported_features: FEATURE_PROTO3_OPTIONAL, which protoc requires before it
+		// passes proto3 optional fields. golang/protobuf v1.3 lacks the field, so it is
+		// encoded by hand as field 2.
+		XXX_unrecognized: []byte{2<<3 | proto.WireVarint, 1},
+	}
 
 	data, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
-		panic(err)
+		fail(resp, fmt.Errorf("reading CodeGeneratorRequest: %v", err))
 	}
 	err = req.Unmarshal(data)
 	if err != nil {
-		panic(err)
+		fail(resp, fmt.Errorf("unmarshalling CodeGeneratorRequest: %v", err))
 	}
 
-	//Assert that a group has been set as a parameter
-	parameters := req.GetParameter()
-	options := make(map[string]string)
-	groupkv := strings.Split(parameters, ",")
-	for _, element := range groupkv {
-		kv := strings.Split(element, "=")
-		if len(kv) > 1 {
-			options[kv[0]] = kv[1]
-		}
+	options, err := generator.ParseOptions(req.GetParameter())
+	if err != nil {
+		fail(resp, err)
 	}
 
 	gen, err := generator.NewControllerGenerator(req, resp, options)
 	if err != nil {
-		panic(err)
+		fail(resp, err)
 	}
 	err = gen.GenerateCode()
 	if err != nil {
-		panic(err)
+		fail(resp, err)
 	}
 
+	write(resp)
+	println()
+	println("In the output directory you can now run `make all`.")
+}
+
+// fail reports the error to protoc through the CodeGeneratorResponse, discarding
+// any partially generated files, and exits without a stack trace.
+func fail(resp *plugin.CodeGeneratorResponse, err error) {
+	message := err.Error()
+	resp.File = nil
+	resp.Error = &message
+	write(resp)
+	fmt.Fprintf(os.Stderr, "protoc-gen-k8s: %s\n", message)
+	os.Exit(1)
+}
+
+func write(resp *plugin.CodeGeneratorResponse) {
 	marshalled, err := proto.Marshal(resp)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "protoc-gen-k8s: marshalling CodeGeneratorResponse: %v\n", err)
+		os.Exit(1)
 	}
 	os.Stdout.Write(marshalled)
-	println()
-	println("In the output directory you can now run `make all`.")
 }
@@ -0,0 +1,660 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	gotemplate "text/template"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	gogen "github.com/golang/protobuf/protoc-gen-go/generator"
+
+	"github.com/drekle/protoc-gen-k8s/pkg/template"
+)
+
+// protoMethodNames are the methods of every golang/protobuf message, which its
+// fields must not collide with
+var protoMethodNames = []string{"Reset", "String", "ProtoMessage", "Marshal", "Unmarshal", "ExtensionRangeArray", "ExtensionMap", "Descriptor"}
+
+// protoNames are the Go names golang/protobuf gives the fields of a message
+type protoNames struct {
+	fields  map[string]string
+	getters map[string]string
+	// oneofs maps the oneof index to its interface field, wrappers the oneof members
+	// to the struct wrapping them
+	oneofs   map[int32]string
+	wrappers map[string]string
+}
+
+// newProtoNames allocates the field names the way protoc-gen-go does, appending
+// underscores until a name is free. nested is the path of Go names of the message.
+func newProtoNames(info *messageInfo, nested []string) *protoNames {
+	names := &protoNames{
+		fields:   make(map[string]string),
+		getters:  make(map[string]string),
+		oneofs:   make(map[int32]string),
+		wrappers: make(map[string]string),
+	}
+	used := make(map[string]bool)
+	for _, name := range protoMethodNames {
+		used[name] = true
+	}
+	alloc := func(ns ...string) []string {
+		for {
+			free := true
+			for _, n := range ns {
+				free = free && !used[n]
+			}
+			if free {
+				break
+			}
+			for i := range ns {
+				ns[i] += "_"
+			}
+		}
+		for _, n := range ns {
+			used[n] = true
+		}
+		return ns
+	}
+	typeName := gogen.CamelCaseSlice(nested)
+	declared := make(map[string]bool)
+	for _, message := range info.Message.GetNestedType() {
+		declared[gogen.CamelCaseSlice(append(append([]string{}, nested...), message.GetName()))] = true
+	}
+	for _, enum := range info.Message.GetEnumType() {
+		declared[gogen.CamelCaseSlice(append(append([]string{}, nested...), enum.GetName()))] = true
+	}
+	for _, field := range info.Message.GetField() {
+		base := gogen.CamelCase(field.GetName())
+		ns := alloc(base, "Get"+base)
+		names.fields[field.GetName()], names.getters[field.GetName()] = ns[0], ns[1]
+		if field.OneofIndex == nil {
+			continue
+		}
+		if _, ok := names.oneofs[field.GetOneofIndex()]; !ok {
+			names.oneofs[field.GetOneofIndex()] = alloc(gogen.CamelCase(info.Message.GetOneofDecl()[field.GetOneofIndex()].GetName()))[0]
+		}
+		wrapper := typeName + "_" + ns[0]
+		for declared[wrapper] {
+			wrapper += "_"
+		}
+		names.wrappers[field.GetName()] = wrapper
+	}
+	return names
+}
+
+// converter renders the converters between the native types of a file and the
+// golang/protobuf messages of pkg/proto
+type converter struct {
+	*nativeTypes
+	// protoPath is the import path of the directory holding the proto packages
+	protoPath string
+	file      *descriptor.FileDescriptorProto
+	imports   map[string]string
+}
+
+// valueConverter converts a single value of a field, ignoring its label
+type valueConverter struct {
+	// to and from are the converting functions, both empty when the value is kept
+	to, from string
+	// err is set when both functions also return an error
+	err bool
+	// pointer is set when to takes a pointer to the native value and from returns one
+	pointer bool
+	// native and proto are the Go types of the value
+	native, proto string
+}
+
+// generateConverters writes the converters and their round trip tests next to the
+// native types of every local proto file
+func (c *controllerGenerator) generateConverters() error {
+	native, err := c.nativeTypes()
+	if err != nil {
+		return err
+	}
+	converters, err := gotemplate.New("Convert").Funcs(template.FuncMap).Parse(template.CONVERT_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	tests, err := gotemplate.New("ConvertTest").Funcs(template.FuncMap).Parse(template.CONVERT_TEST_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	var errs GeneratorErrors
+	for _, filename := range c.registry.Dependencies(c.Request.FileToGenerate) {
+		file := c.registry.File(filename)
+		if !c.isLocalFile(file) {
+			continue
+		}
+		conv := &converter{
+			nativeTypes: native,
+			protoPath:   path.Join(c.RepoURL, "pkg", "proto"),
+			file:        file,
+		}
+		base := fmt.Sprintf("pkg/apis/%s/%s/%s", strings.Replace(c.Opts.Group, ".", "", -1), file.GetPackage(), strings.TrimSuffix(path.Base(filename), ".proto"))
+		convertFile, err := conv.convertFile()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := c.runTemplate(base+"Convert.go", converters, convertFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", filename, err))
+		}
+		testFile, err := conv.testFile()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := c.runTemplate(base+"Convert_test.go", tests, testFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", filename, err))
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// convertFile converts the enums and messages of the file, and the kinds declared by them
+func (c *converter) convertFile() (*template.ConvertFile, error) {
+	c.imports = map[string]string{"fmt": "fmt"}
+	ret := &template.ConvertFile{
+		Package: c.file.GetPackage(),
+		Source:  c.file.GetName(),
+	}
+	for _, enum := range c.enums(c.file) {
+		ret.Enums = append(ret.Enums, template.EnumConverter{
+			Name:  c.enumName(enum),
+			Proto: c.protoType(enum.File, enum.Nested),
+		})
+	}
+	var errs GeneratorErrors
+	for _, message := range c.messages(c.file) {
+		converter, err := c.message(message)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ret.Messages = append(ret.Messages, converter)
+		if kind, ok := c.kinds[qualifiedName(c.file.GetPackage(), message.Nested...)]; ok {
+			ret.Kinds = append(ret.Kinds, template.KindConverter{
+				Name:  kind,
+				Spec:  converter.Name,
+				Proto: converter.Proto,
+			})
+		}
+	}
+	code := make([]string, 0)
+	for _, message := range ret.Messages {
+		code = append(append(append(code, message.Proto), message.ToProto...), message.FromProto...)
+	}
+	for _, enum := range ret.Enums {
+		code = append(code, enum.Proto, "fmt.")
+	}
+	ret.Imports = usedImports(c.imports, code)
+	return ret, errs.errorOrNil()
+}
+
+// protoType is the golang/protobuf type of a message or enum declared in file
+func (c *converter) protoType(file *descriptor.FileDescriptorProto, nested []string) string {
+	return c.protoQualify(file, gogen.CamelCaseSlice(nested))
+}
+
+// protoQualify refers to a Go name declared in the proto package of file
+func (c *converter) protoQualify(file *descriptor.FileDescriptorProto, name string) string {
+	alias := file.GetPackage() + "pb"
+	c.imports[alias] = path.Join(c.protoPath, file.GetPackage())
+	return alias + "." + name
+}
+
+// value returns the converter of a single value of the field
+func (c *converter) value(field *descriptor.FieldDescriptorProto) (*valueConverter, error) {
+	goType, err := c.valueType(c.file, field, c.imports)
+	if err != nil {
+		return nil, err
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		info := c.registry.Enum(field.GetTypeName())
+		to, err := c.qualify(c.file, info.File, c.enumName(info)+"ToProto", c.imports)
+		if err != nil {
+			return nil, err
+		}
+		from, _ := c.qualify(c.file, info.File, c.enumName(info)+"FromProto", c.imports)
+		return &valueConverter{to: to, from: from, err: true, native: goType, proto: c.protoType(info.File, info.Nested)}, nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if wellKnown, ok := wellKnownTypes[field.GetTypeName()]; ok {
+			c.imports["convert"] = path.Join(path.Dir(c.protoPath), "convert")
+			c.imports[wellKnown.ProtoImport.Alias] = wellKnown.ProtoImport.Path
+			return &valueConverter{
+				to:      "convert." + wellKnown.Helper + "ToProto",
+				from:    "convert." + wellKnown.Helper + "FromProto",
+				err:     wellKnown.HelperErr,
+				pointer: wellKnown.Pointer,
+				native:  goType,
+				proto:   "*" + wellKnown.ProtoType,
+			}, nil
+		}
+		info := c.registry.Message(field.GetTypeName())
+		name := c.messageName(info)
+		to, err := c.qualify(c.file, info.File, name+"ToProto", c.imports)
+		if err != nil {
+			return nil, err
+		}
+		from, _ := c.qualify(c.file, info.File, name+"FromProto", c.imports)
+		return &valueConverter{to: to, from: from, err: true, pointer: true, native: goType, proto: "*" + c.protoType(info.File, info.Nested)}, nil
+	}
+	return &valueConverter{native: goType, proto: goType}, nil
+}
+
+// message renders the statements converting each field of the message
+func (c *converter) message(info *messageInfo) (template.MessageConverter, error) {
+	ret := template.MessageConverter{
+		Name:  c.messageName(info),
+		Proto: c.protoType(info.File, info.Nested),
+	}
+	names := newProtoNames(info, info.Nested)
+	var errs GeneratorErrors
+	for _, field := range info.Message.GetField() {
+		var to, from string
+		var err error
+		switch {
+		case field.OneofIndex != nil:
+			if first := c.oneofMembers(info.Message, field)[0]; first != field {
+				continue
+			}
+			to, from, err = c.oneof(info, names, field)
+		case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+			to, from, err = c.repeated(field)
+		default:
+			to, from, err = c.singular(names, field)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: message %s: field %s: %v", info.File.GetName(), strings.TrimPrefix(qualifiedName(info.File.GetPackage(), info.Nested...), "."), field.GetName(), err))
+			continue
+		}
+		ret.ToProto = append(ret.ToProto, to)
+		ret.FromProto = append(ret.FromProto, from)
+	}
+	for _, statement := range ret.ToProto {
+		ret.ToProtoErr = ret.ToProtoErr || strings.Contains(statement, ", err = ")
+	}
+	for _, statement := range ret.FromProto {
+		ret.FromProtoErr = ret.FromProtoErr || strings.Contains(statement, ", err = ")
+	}
+	return ret, errs.errorOrNil()
+}
+
+// oneofMembers lists the fields of the oneof of field in declaration order
+func (c *converter) oneofMembers(message *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) []*descriptor.FieldDescriptorProto {
+	members := make([]*descriptor.FieldDescriptorProto, 0)
+	for _, other := range message.GetField() {
+		if other.OneofIndex != nil && other.GetOneofIndex() == field.GetOneofIndex() {
+			members = append(members, other)
+		}
+	}
+	return members
+}
+
+// assign converts src into dst, which are both of the field type
+func assign(dst, src, fn string, err bool, jsonName string) string {
+	if !err {
+		return fmt.Sprintf("%s = %s(%s)", dst, fn, src)
+	}
+	return fmt.Sprintf("if %s, err = %s(%s); err != nil {\nreturn nil, fmt.Errorf(\"%s: %%v\", err)\n}", dst, fn, src, jsonName)
+}
+
+// assignValue converts src into dst like assign, or copies it when there is no
+// converting function
+func assignValue(dst, src, fn string, err bool, jsonName string) string {
+	if fn == "" {
+		return fmt.Sprintf("%s = %s", dst, src)
+	}
+	return assign(dst, src, fn, err, jsonName)
+}
+
+// nonZero is the condition that the scalar or enum value of the field is not zero
+func nonZero(field *descriptor.FieldDescriptorProto, value string) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return value + ` != ""`
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return value
+	}
+	return value + " != 0"
+}
+
+// singular converts a field which is neither repeated nor a oneof member
+func (c *converter) singular(names *protoNames, field *descriptor.FieldDescriptorProto) (string, string, error) {
+	value, err := c.value(field)
+	if err != nil {
+		return "", "", err
+	}
+	name := gogen.CamelCase(field.GetName())
+	protoName := names.fields[field.GetName()]
+	jsonName := nativeJSONName(field)
+	in, out := "in."+name, "out."+name
+	protoIn, protoOut := "in."+protoName, "out."+protoName
+	bytes := field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES
+	proto2 := c.file.GetSyntax() != "proto3"
+	required := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED
+	switch {
+	case c.presence(field):
+		// The native field points to the value, the proto field holds it without
+		// presence so its zero value converts to unset
+		return fmt.Sprintf("if %s != nil {\n%s\n}", in, assignValue(protoOut, "*"+in, value.to, value.err, jsonName)),
+			fmt.Sprintf("if %s {\n%s = new(%s)\n%s\n}", nonZero(field, protoIn), out, value.native, assignValue("*"+out, protoIn, value.from, value.err, jsonName)), nil
+	case value.to == "" && proto2 && required && !bytes:
+		// The native field holds the value, the proto field a pointer to it
+		return fmt.Sprintf("%s = new(%s)\n*%s = %s", protoOut, value.proto, protoOut, in),
+			fmt.Sprintf("%s = in.%s()", out, names.getters[field.GetName()]), nil
+	case value.to == "":
+		return fmt.Sprintf("%s = %s", protoOut, in), fmt.Sprintf("%s = %s", out, protoIn), nil
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM && proto2 && required:
+		return fmt.Sprintf("%s = new(%s)\n%s", protoOut, value.proto, assign("*"+protoOut, in, value.to, true, jsonName)),
+			assign(out, "in."+names.getters[field.GetName()]+"()", value.from, true, jsonName), nil
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM && proto2:
+		return fmt.Sprintf("if %s != nil {\n%s = new(%s)\n%s\n}", in, protoOut, value.proto, assign("*"+protoOut, "*"+in, value.to, true, jsonName)),
+			fmt.Sprintf("if %s != nil {\n%s = new(%s)\n%s\n}", protoIn, out, value.native, assign("*"+out, "*"+protoIn, value.from, true, jsonName)), nil
+	}
+	return assign(protoOut, in, value.to, value.err, jsonName), assign(out, protoIn, value.from, value.err, jsonName), nil
+}
+
+// repeated converts a repeated field or a map one value at a time
+func (c *converter) repeated(field *descriptor.FieldDescriptorProto) (string, string, error) {
+	name := gogen.CamelCase(field.GetName())
+	jsonName := nativeJSONName(field)
+	entry := c.registry.Message(field.GetTypeName())
+	isMap := entry != nil && entry.Message.GetOptions().GetMapEntry()
+	valueField := field
+	if isMap {
+		valueField = entry.Message.GetField()[1]
+	}
+	value, err := c.value(valueField)
+	if err != nil {
+		return "", "", err
+	}
+	if value.to == "" {
+		return fmt.Sprintf("out.%s = in.%s", name, name), fmt.Sprintf("out.%s = in.%s", name, name), nil
+	}
+
+	// convert renders the conversion of src into w, stopping at the first error
+	convert := func(fn, src, index string) string {
+		if !value.err {
+			return fmt.Sprintf("w := %s(%s)", fn, src)
+		}
+		return fmt.Sprintf("w, err := %s(%s)\nif err != nil {\nreturn nil, fmt.Errorf(\"%s[%%v]: %%v\", %s, err)\n}", fn, src, jsonName, index)
+	}
+	// deref stores w with dst, a nil message in a list or map becomes the zero value
+	deref := func(dst string) string {
+		if value.pointer {
+			return fmt.Sprintf("if w == nil {\nw = new(%s)\n}\n%s*w", value.native, dst)
+		}
+		return dst + "w"
+	}
+	src := "in." + name + "[i]"
+	if value.pointer {
+		src = "&" + src
+	}
+	if !isMap {
+		index := "_"
+		if value.err {
+			index = "i"
+		}
+		to := fmt.Sprintf("for i := range in.%s {\n%s\nout.%s = append(out.%s, w)\n}", name, convert(value.to, src, "i"), name, name)
+		from := fmt.Sprintf("for %s, v := range in.%s {\n%s\n%s)\n}", index, name, convert(value.from, "v", "i"), deref(fmt.Sprintf("out.%s = append(out.%s, ", name, name)))
+		return to, from, nil
+	}
+	key, err := c.valueType(c.file, entry.Message.GetField()[0], c.imports)
+	if err != nil {
+		return "", "", err
+	}
+	src = "v"
+	if value.pointer {
+		src = "&v"
+	}
+	to := fmt.Sprintf("if in.%s != nil {\nout.%s = make(map[%s]%s, len(in.%s))\nfor k, v := range in.%s {\n%s\nout.%s[k] = w\n}\n}",
+		name, name, key, value.proto, name, name, convert(value.to, src, "k"), name)
+	from := fmt.Sprintf("if in.%s != nil {\nout.%s = make(map[%s]%s, len(in.%s))\nfor k, v := range in.%s {\n%s\n%s\n}\n}",
+		name, name, key, value.native, name, name, convert(value.from, "v", "k"), deref(fmt.Sprintf("out.%s[k] = ", name)))
+	return to, from, nil
+}
+
+// oneof converts the members of the oneof of field, which native types declare as
+// optional fields
+func (c *converter) oneof(info *messageInfo, names *protoNames, field *descriptor.FieldDescriptorProto) (string, string, error) {
+	oneofName := names.oneofs[field.GetOneofIndex()]
+	members := c.oneofMembers(info.Message, field)
+	jsonNames := make([]string, 0)
+	for _, member := range members {
+		jsonNames = append(jsonNames, nativeJSONName(member))
+	}
+	to := make([]string, 0)
+	from := make([]string, 0)
+	for i, member := range members {
+		value, err := c.value(member)
+		if err != nil {
+			return "", "", err
+		}
+		name := gogen.CamelCase(member.GetName())
+		protoName := names.fields[member.GetName()]
+		wrapper := c.protoQualify(info.File, names.wrappers[member.GetName()])
+		jsonName := nativeJSONName(member)
+		bytes := member.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES
+
+		statements := make([]string, 0)
+		if i > 0 {
+			statements = append(statements, fmt.Sprintf("if out.%s != nil {\nreturn nil, fmt.Errorf(\"at most one of %s may be set\")\n}", oneofName, strings.Join(jsonNames, ", ")))
+		}
+		src := "in." + name
+		if (value.to == "" && !bytes) || member.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM {
+			src = "*" + src
+		}
+		switch {
+		case value.to == "":
+			statements = append(statements, fmt.Sprintf("out.%s = &%s{%s: %s}", oneofName, wrapper, protoName, src))
+		case value.err:
+			statements = append(statements, fmt.Sprintf("v, err := %s(%s)\nif err != nil {\nreturn nil, fmt.Errorf(\"%s: %%v\", err)\n}\nout.%s = &%s{%s: v}", value.to, src, jsonName, oneofName, wrapper, protoName))
+		default:
+			statements = append(statements, fmt.Sprintf("out.%s = &%s{%s: %s(%s)}", oneofName, wrapper, protoName, value.to, src))
+		}
+		to = append(to, fmt.Sprintf("if in.%s != nil {\n%s\n}", name, strings.Join(statements, "\n")))
+
+		var assignment string
+		switch {
+		case value.to == "" && bytes:
+			assignment = fmt.Sprintf("out.%s = v.%s", name, protoName)
+		case value.to == "":
+			assignment = fmt.Sprintf("out.%s = new(%s)\n*out.%s = v.%s", name, value.native, name, protoName)
+		case member.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM:
+			assignment = fmt.Sprintf("out.%s = new(%s)\n%s", name, value.native, assign("*out."+name, "v."+protoName, value.from, true, jsonName))
+		default:
+			assignment = assign("out."+name, "v."+protoName, value.from, value.err, jsonName)
+		}
+		from = append(from, fmt.Sprintf("case *%s:\n%s", wrapper, assignment))
+	}
+	return strings.Join(to, "\n"), fmt.Sprintf("switch v := in.%s.(type) {\n%s\n}", oneofName, strings.Join(from, "\n")), nil
+}
+
+// usedImports lists the imports referred to by the code by path. The native types
+// record the imports of every type they name, not all of which a converter needs.
+func usedImports(imports map[string]string, code []string) []template.NativeImport {
+	joined := strings.Join(code, "\n")
+	ret := make([]template.NativeImport, 0)
+	for alias, importPath := range imports {
+		if !strings.Contains(joined, alias+".") {
+			continue
+		}
+		if alias == path.Base(importPath) && !strings.Contains(importPath, ".") {
+			alias = ""
+		}
+		ret = append(ret, template.NativeImport{Alias: alias, Path: importPath})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Path < ret[j].Path
+	})
+	return ret
+}
+
+// testFile renders a round trip test per message of the file, converting a message
+// with every field set. Kinds are converted as a whole.
+func (c *converter) testFile() (*template.ConvertTestFile, error) {
+	c.imports = make(map[string]string)
+	ret := &template.ConvertTestFile{
+		Package: c.file.GetPackage(),
+		Source:  c.file.GetName(),
+	}
+	var errs GeneratorErrors
+	samples := make([]string, 0)
+	for _, message := range c.messages(c.file) {
+		sample, err := c.sample(message, make(map[string]bool))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		test := template.ConvertTest{Name: c.messageName(message), Sample: sample}
+		if kind, ok := c.kinds[qualifiedName(c.file.GetPackage(), message.Nested...)]; ok {
+			test.Name, test.Kind = kind, true
+		}
+		ret.Tests = append(ret.Tests, test)
+		samples = append(samples, sample)
+	}
+	ret.Imports = usedImports(c.imports, samples)
+	return ret, errs.errorOrNil()
+}
+
+// protoPointers are the helpers of the proto package allocating a scalar
+var protoPointers = map[string]string{
+	"float64": "proto.Float64",
+	"float32": "proto.Float32",
+	"int32":   "proto.Int32",
+	"int64":   "proto.Int64",
+	"uint32":  "proto.Uint32",
+	"uint64":  "proto.Uint64",
+	"bool":    "proto.Bool",
+	"string":  "proto.String",
+}
+
+// sample renders a literal of the message with every field set. Messages already
+// being sampled are left out, so that recursive messages end.
+func (c *converter) sample(info *messageInfo, visiting map[string]bool) (string, error) {
+	name := qualifiedName(info.File.GetPackage(), info.Nested...)
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	names := newProtoNames(info, info.Nested)
+	fields := make([]string, 0)
+	sampledOneofs := make(map[int32]bool)
+	var errs GeneratorErrors
+	for _, field := range info.Message.GetField() {
+		value, ok, err := c.sampleField(info, names, field, visiting)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: message %s: field %s: %v", info.File.GetName(), strings.TrimPrefix(name, "."), field.GetName(), err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if field.OneofIndex != nil {
+			// Only a single member of a oneof is set
+			if sampledOneofs[field.GetOneofIndex()] {
+				continue
+			}
+			sampledOneofs[field.GetOneofIndex()] = true
+			value = fmt.Sprintf("&%s{%s: %s}", c.protoQualify(info.File, names.wrappers[field.GetName()]), names.fields[field.GetName()], value)
+			fields = append(fields, fmt.Sprintf("%s: %s,", names.oneofs[field.GetOneofIndex()], value))
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s: %s,", names.fields[field.GetName()], value))
+	}
+	literal := "&" + c.protoType(info.File, info.Nested) + "{"
+	if len(fields) > 0 {
+		literal += "\n" + strings.Join(fields, "\n") + "\n"
+	}
+	return literal + "}", errs.errorOrNil()
+}
+
+// sampleField renders a value of the field, reporting false when it is left out
+func (c *converter) sampleField(info *messageInfo, names *protoNames, field *descriptor.FieldDescriptorProto, visiting map[string]bool) (string, bool, error) {
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		entry := c.registry.Message(field.GetTypeName())
+		valueField := field
+		if entry != nil && entry.Message.GetOptions().GetMapEntry() {
+			valueField = entry.Message.GetField()[1]
+		}
+		value, err := c.value(valueField)
+		if err != nil {
+			return "", false, err
+		}
+		sample, ok, err := c.sampleValue(valueField, visiting)
+		if err != nil || !ok {
+			return "", false, err
+		}
+		if valueField == field {
+			return fmt.Sprintf("[]%s{%s}", value.proto, sample), true, nil
+		}
+		keyField := entry.Message.GetField()[0]
+		key, err := c.valueType(c.file, keyField, c.imports)
+		if err != nil {
+			return "", false, err
+		}
+		keySample, _, err := c.sampleValue(keyField, visiting)
+		if err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("map[%s]%s{%s: %s}", key, value.proto, keySample, sample), true, nil
+	}
+	sample, ok, err := c.sampleValue(field, visiting)
+	if err != nil || !ok || c.file.GetSyntax() == "proto3" || field.OneofIndex != nil {
+		return sample, ok, err
+	}
+	// proto2 fields hold pointers to scalars and enums
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BYTES, descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		return sample, true, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return sample + ".Enum()", true, nil
+	}
+	goType, err := c.valueType(c.file, field, c.imports)
+	if err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf("%s(%s)", protoPointers[goType], sample), true, nil
+}
+
+// sampleValue renders a single value of the field, ignoring its label
+func (c *converter) sampleValue(field *descriptor.FieldDescriptorProto, visiting map[string]bool) (string, bool, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "1.5", true, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "true", true, nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return `"a"`, true, nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return `[]byte("a")`, true, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		info := c.registry.Enum(field.GetTypeName())
+		if info == nil {
+			return "", false, fmt.Errorf("enum `%s` not found", field.GetTypeName())
+		}
+		// The last value is the one least likely to be the zero value
+		values := info.Enum.GetValue()
+		return fmt.Sprintf("%s(%d)", c.protoType(info.File, info.Nested), values[len(values)-1].GetNumber()), true, nil
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if wellKnown, ok := wellKnownTypes[field.GetTypeName()]; ok {
+			c.imports[wellKnown.ProtoImport.Alias] = wellKnown.ProtoImport.Path
+			return wellKnown.ProtoSample, true, nil
+		}
+		info := c.registry.Message(field.GetTypeName())
+		if info == nil {
+			return "", false, fmt.Errorf("message `%s` not found", field.GetTypeName())
+		}
+		if visiting[field.GetTypeName()] {
+			return "", false, nil
+		}
+		sample, err := c.sample(info, visiting)
+		return sample, err == nil, err
+	}
+	return "1", true, nil
+}
@@ -0,0 +1,167 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestNewProtoNames(t *testing.T) {
+	r := testRegistry()
+	names := newProtoNames(r.Message(".v1.Spec"), []string{"Spec"})
+	if names.fields["labels"] != "Labels" || names.getters["labels"] != "GetLabels" {
+		t.Errorf("labels is named %s and %s, want Labels and GetLabels", names.fields["labels"], names.getters["labels"])
+	}
+	if want := map[int32]string{0: "Choice"}; !reflect.DeepEqual(names.oneofs, want) {
+		t.Errorf("oneofs = %v, want %v", names.oneofs, want)
+	}
+	if want := map[string]string{"choice_a": "Spec_ChoiceA"}; !reflect.DeepEqual(names.wrappers, want) {
+		t.Errorf("wrappers = %v, want %v", names.wrappers, want)
+	}
+
+	// protoc-gen-go appends underscores to the names taken by methods, other fields
+	// and nested declarations
+	member := testField("a", 4, descriptor.FieldDescriptorProto_TYPE_STRING, "")
+	member.OneofIndex = proto.Int32(0)
+	info := &messageInfo{
+		Message: &descriptor.DescriptorProto{
+			Name: proto.String("Collide"),
+			Field: []*descriptor.FieldDescriptorProto{
+				testField("reset", 1, descriptor.FieldDescriptorProto_TYPE_BOOL, ""),
+				testField("name", 2, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+				testField("get_name", 3, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+				member,
+			},
+			NestedType: []*descriptor.DescriptorProto{{Name: proto.String("A")}},
+			OneofDecl:  []*descriptor.OneofDescriptorProto{{Name: proto.String("name")}},
+		},
+		Nested: []string{"Collide"},
+	}
+	names = newProtoNames(info, []string{"Outer", "Collide"})
+	tests := []struct {
+		field          string
+		goName, getter string
+	}{
+		{"reset", "Reset_", "GetReset_"},
+		{"name", "Name", "GetName"},
+		{"get_name", "GetName_", "GetGetName_"},
+		{"a", "A", "GetA"},
+	}
+	for _, test := range tests {
+		if names.fields[test.field] != test.goName || names.getters[test.field] != test.getter {
+			t.Errorf("%s is named %s and %s, want %s and %s", test.field, names.fields[test.field], names.getters[test.field], test.goName, test.getter)
+		}
+	}
+	if names.oneofs[0] != "Name_" {
+		t.Errorf("the oneof is named %s, want Name_", names.oneofs[0])
+	}
+	if names.wrappers["a"] != "Outer_Collide_A_" {
+		t.Errorf("the wrapper of a is named %s, want Outer_Collide_A_", names.wrappers["a"])
+	}
+}
+
+func TestConverterSingular(t *testing.T) {
+	r := testRegistry()
+	native := testNativeTypes(r)
+	native.defaulted[testMessageField(r, ".v1.Spec", "count")] = true
+	tests := []struct {
+		message  string
+		field    string
+		to, from string
+	}{
+		{
+			message: ".v1.Spec",
+			field:   "name",
+			to:      "out.Name = in.Name",
+			from:    "out.Name = in.Name",
+		},
+		{
+			message: ".v1.Spec",
+			field:   "count",
+			to:      "if in.Count != nil {\nout.Count = *in.Count\n}",
+			from:    "if in.Count != 0 {\nout.Count = new(int32)\n*out.Count = in.Count\n}",
+		},
+		{
+			message: ".v1.Spec",
+			field:   "color",
+			to:      "if out.Color, err = ColorToProto(in.Color); err != nil {\nreturn nil, fmt.Errorf(\"color: %v\", err)\n}",
+			from:    "if out.Color, err = ColorFromProto(in.Color); err != nil {\nreturn nil, fmt.Errorf(\"color: %v\", err)\n}",
+		},
+		{
+			message: ".v1.Spec",
+			field:   "spec",
+			to:      "if out.Spec, err = SpecToProto(in.Spec); err != nil {\nreturn nil, fmt.Errorf(\"spec: %v\", err)\n}",
+			from:    "if out.Spec, err = SpecFromProto(in.Spec); err != nil {\nreturn nil, fmt.Errorf(\"spec: %v\", err)\n}",
+		},
+		{
+			message: ".v2.Legacy",
+			field:   "id",
+			to:      "out.Id = new(string)\n*out.Id = in.Id",
+			from:    "out.Id = in.GetId()",
+		},
+		{
+			message: ".v2.Legacy",
+			field:   "replicas",
+			to:      "out.Replicas = in.Replicas",
+			from:    "out.Replicas = in.Replicas",
+		},
+	}
+	for _, test := range tests {
+		info := r.Message(test.message)
+		c := &converter{nativeTypes: native, protoPath: "github.com/example/controller/pkg/proto", file: info.File, imports: make(map[string]string)}
+		to, from, err := c.singular(newProtoNames(info, info.Nested), testMessageField(r, test.message, test.field))
+		if err != nil {
+			t.Fatalf("%s: singular error = %v", test.field, err)
+		}
+		if to != test.to {
+			t.Errorf("%s: to proto\n%s\nwant\n%s", test.field, to, test.to)
+		}
+		if from != test.from {
+			t.Errorf("%s: from proto\n%s\nwant\n%s", test.field, from, test.from)
+		}
+	}
+}
+
+func TestConverterMessage(t *testing.T) {
+	r := testRegistry()
+	info := r.Message(".v1.Spec")
+	// protoc keeps the case of json_name
+	member := testField("ChoiceB", 12, descriptor.FieldDescriptorProto_TYPE_INT64, "")
+	member.OneofIndex = proto.Int32(0)
+	info.Message.Field = append(info.Message.Field, member)
+	c := &converter{nativeTypes: testNativeTypes(r), protoPath: "github.com/example/controller/pkg/proto", file: info.File, imports: make(map[string]string)}
+	message, err := c.message(info)
+	if err != nil {
+		t.Fatalf("message error = %v", err)
+	}
+	if message.Name != "Spec" || message.Proto != "v1pb.Spec" {
+		t.Errorf("message converts %s to %s, want Spec to v1pb.Spec", message.Name, message.Proto)
+	}
+	if c.imports["v1pb"] != "github.com/example/controller/pkg/proto/v1" {
+		t.Errorf("v1pb imports %q", c.imports["v1pb"])
+	}
+	// One statement for every field but the members of the oneof after the first
+	if len(message.ToProto) != 11 || len(message.FromProto) != 11 {
+		t.Errorf("message has %d and %d statements, want 11", len(message.ToProto), len(message.FromProto))
+	}
+	if !message.ToProtoErr || !message.FromProtoErr {
+		t.Errorf("the enum and message conversions do not return errors")
+	}
+	tests := []struct {
+		statements []string
+		want       string
+	}{
+		{message.ToProto, "out.Labels = in.Labels"},
+		{message.ToProto, "if in.ChoiceA != nil {\nout.Choice = &v1pb.Spec_ChoiceA{ChoiceA: *in.ChoiceA}\n}\nif in.ChoiceB != nil {\nif out.Choice != nil {\nreturn nil, fmt.Errorf(\"at most one of choiceA, choiceB may be set\")\n}\nout.Choice = &v1pb.Spec_ChoiceB{ChoiceB: *in.ChoiceB}\n}"},
+		{message.FromProto, "switch v := in.Choice.(type) {\ncase *v1pb.Spec_ChoiceA:\nout.ChoiceA = new(string)\n*out.ChoiceA = v.ChoiceA\ncase *v1pb.Spec_ChoiceB:\nout.ChoiceB = new(int64)\n*out.ChoiceB = v.ChoiceB\n}"},
+	}
+	for _, test := range tests {
+		code := strings.Join(test.statements, "\n")
+		if !strings.Contains(code, test.want) {
+			t.Errorf("the statements lack\n%s\nin\n%s", test.want, code)
+		}
+	}
+}
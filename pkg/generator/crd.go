@@ -0,0 +1,472 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	gogen "github.com/golang/protobuf/protoc-gen-go/generator"
+	"sigs.k8s.io/yaml"
+
+	gotemplate "text/template"
+
+	"github.com/drekle/protoc-gen-k8s/pkg/template"
+)
+
+// JSONSchemaProps is the subset of the apiextensions.k8s.io/v1 OpenAPI v3 schema
+// emitted for generated CustomResourceDefinitions.
+type JSONSchemaProps struct {
+	Description          string                      `json:"description,omitempty"`
+	Type                 string                      `json:"type,omitempty"`
+	Format               string                      `json:"format,omitempty"`
+	Properties           map[string]*JSONSchemaProps `json:"properties,omitempty"`
+	Items                *JSONSchemaProps            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchemaProps            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}               `json:"enum,omitempty"`
+	Default              interface{}                 `json:"default,omitempty"`
+	Required             []string                    `json:"required,omitempty"`
+	Minimum              *float64                    `json:"minimum,omitempty"`
+	Maximum              *float64                    `json:"maximum,omitempty"`
+	Pattern              string                      `json:"pattern,omitempty"`
+	MinLength            *int64                      `json:"minLength,omitempty"`
+	MaxLength            *int64                      `json:"maxLength,omitempty"`
+	MinItems             *int64                      `json:"minItems,omitempty"`
+	MaxItems             *int64                      `json:"maxItems,omitempty"`
+	MaxProperties        *int64                      `json:"maxProperties,omitempty"`
+	PreserveUnknown      *bool                       `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+}
+
+type customResourceDefinition struct {
+	APIVersion string                       `json:"apiVersion"`
+	Kind       string                       `json:"kind"`
+	Metadata   customResourceMetadata       `json:"metadata"`
+	Spec       customResourceDefinitionSpec `json:"spec"`
+}
+
+type customResourceMetadata struct {
+	Name string `json:"name"`
+}
+
+type customResourceDefinitionSpec struct {
+	Group    string                     `json:"group"`
+	Names    customResourceNames        `json:"names"`
+	Scope    string                     `json:"scope"`
+	Versions []customResourceDefVersion `json:"versions"`
+}
+
+type customResourceNames struct {
+	Kind       string   `json:"kind"`
+	ListKind   string   `json:"listKind"`
+	Plural     string   `json:"plural"`
+	Singular   string   `json:"singular"`
+	ShortNames []string `json:"shortNames,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+type customResourceDefVersion struct {
+	Name         string                  `json:"name"`
+	Served       bool                    `json:"served"`
+	Storage      bool                    `json:"storage"`
+	Schema       customResourceSchema    `json:"schema"`
+	Subresources *customResourceSubresrc `json:"subresources,omitempty"`
+}
+
+type customResourceSchema struct {
+	OpenAPIV3Schema *JSONSchemaProps `json:"openAPIV3Schema"`
+}
+
+type customResourceSubresrc struct {
+	Status *struct{} `json:"status,omitempty"`
+}
+
+// schemaBuilder converts proto descriptors into structural OpenAPI v3 schemas
+type schemaBuilder struct {
+	registry *registry
+	// native follows the JSON of the types generated with types=native rather
+	// than the golang/protobuf messages
+	native      bool
+	validations fieldValidations
+	defaults    fieldDefaults
+}
+
+func newSchemaBuilder(registry *registry, native bool, validations fieldValidations, defaults fieldDefaults) *schemaBuilder {
+	return &schemaBuilder{registry: registry, native: native, validations: validations, defaults: defaults}
+}
+
+// description strips annotation lines such as +genclient from a comment
+func description(comment string) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "+") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// messageSchema builds the schema of the fully qualified message. Messages already
+// on the stack are recursive and cannot be expressed structurally, so they accept
+// any object.
+func (b *schemaBuilder) messageSchema(name string, stack map[string]bool) (*JSONSchemaProps, error) {
+	info := b.registry.Message(name)
+	if info == nil {
+		return nil, fmt.Errorf("message `%s` not found", name)
+	}
+	message := info.Message
+	schema := &JSONSchemaProps{
+		Type:        "object",
+		Description: description(info.Comments),
+	}
+	if stack[name] {
+		schema.PreserveUnknown = boolPtr(true)
+		return schema, nil
+	}
+	stack[name] = true
+	defer delete(stack, name)
+
+	schema.Properties = make(map[string]*JSONSchemaProps)
+	oneofs := make(map[int32]*JSONSchemaProps)
+	for _, field := range message.GetField() {
+		fieldSchema, err := b.fieldSchema(field, stack)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", name, field.GetName(), err)
+		}
+		// Field comments take precedence, except over the list of enum values
+		if comment := description(info.FieldComments[field.GetName()]); comment != "" {
+			if fieldSchema.Enum != nil && fieldSchema.Description != "" && !b.native {
+				comment = comment + "\n" + fieldSchema.Description
+			}
+			fieldSchema.Description = comment
+		}
+		validation := b.validations.get(name, field.GetName())
+		if validation != nil {
+			validation.apply(fieldSchema)
+		}
+		if fieldDefault := b.defaults.get(name, field.GetName()); fieldDefault != nil {
+			fieldSchema.Default = fieldDefault.Value
+		}
+		if b.native {
+			// Oneof members are optional fields of the native struct
+			if oneof := oneofComment(message, field); oneof != "" {
+				fieldSchema.Description = strings.TrimSpace(fieldSchema.Description + "\n" + oneof)
+			}
+			if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED || validation != nil && validation.Required {
+				schema.Required = append(schema.Required, nativeJSONName(field))
+			}
+			schema.Properties[nativeJSONName(field)] = fieldSchema
+			continue
+		}
+		if field.OneofIndex == nil {
+			if validation != nil && validation.Required {
+				schema.Required = append(schema.Required, field.GetName())
+			}
+			schema.Properties[field.GetName()] = fieldSchema
+			continue
+		}
+		// golang/protobuf wraps each oneof member in a struct named after the Go field
+		oneof, ok := oneofs[field.GetOneofIndex()]
+		if !ok {
+			oneof = &JSONSchemaProps{
+				Type:          "object",
+				Properties:    make(map[string]*JSONSchemaProps),
+				MaxProperties: int64Ptr(1),
+			}
+			oneofs[field.GetOneofIndex()] = oneof
+			oneofName := message.GetOneofDecl()[field.GetOneofIndex()].GetName()
+			schema.Properties[gogen.CamelCase(oneofName)] = oneof
+		}
+		oneof.Properties[gogen.CamelCase(field.GetName())] = fieldSchema
+	}
+	return schema, nil
+}
+
+func (b *schemaBuilder) fieldSchema(field *descriptor.FieldDescriptorProto, stack map[string]bool) (*JSONSchemaProps, error) {
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		if entry := b.registry.Message(field.GetTypeName()); entry != nil && entry.Message.GetOptions().GetMapEntry() {
+			// Map keys are always serialized as JSON strings
+			value, err := b.valueSchema(entry.Message.GetField()[1], stack)
+			if err != nil {
+				return nil, err
+			}
+			return &JSONSchemaProps{Type: "object", AdditionalProperties: value}, nil
+		}
+		items, err := b.valueSchema(field, stack)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchemaProps{Type: "array", Items: items}, nil
+	}
+	return b.valueSchema(field, stack)
+}
+
+// valueSchema builds the schema of a single value of the field, ignoring its label
+func (b *schemaBuilder) valueSchema(field *descriptor.FieldDescriptorProto, stack map[string]bool) (*JSONSchemaProps, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return &JSONSchemaProps{Type: "number", Format: "double"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return &JSONSchemaProps{Type: "number", Format: "float"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return &JSONSchemaProps{Type: "integer", Format: "int32"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return &JSONSchemaProps{Type: "integer", Format: "int64"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return &JSONSchemaProps{Type: "integer", Minimum: float64Ptr(0)}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return &JSONSchemaProps{Type: "boolean"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return &JSONSchemaProps{Type: "string"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return &JSONSchemaProps{Type: "string", Format: "byte"}, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return b.enumSchema(field.GetTypeName())
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if wellKnown, ok := wellKnownTypes[field.GetTypeName()]; ok && b.native {
+			return wellKnown.Schema(), nil
+		}
+		return b.messageSchema(field.GetTypeName(), stack)
+	}
+	return nil, fmt.Errorf("unsupported field type %s", field.GetType())
+}
+
+// enumSchema describes an enum the way golang/protobuf serializes it to JSON: as
+// its numeric value. Native enums are strings.
+func (b *schemaBuilder) enumSchema(name string) (*JSONSchemaProps, error) {
+	info := b.registry.Enum(name)
+	if info == nil {
+		return nil, fmt.Errorf("enum `%s` not found", name)
+	}
+	enum := info.Enum
+	if b.native {
+		// Native enums are serialized by name
+		schema := &JSONSchemaProps{Type: "string", Description: description(info.Comments)}
+		for _, value := range enum.GetValue() {
+			schema.Enum = append(schema.Enum, value.GetName())
+		}
+		return schema, nil
+	}
+	schema := &JSONSchemaProps{Type: "integer", Format: "int32"}
+	values := make([]string, 0, len(enum.GetValue()))
+	for _, value := range enum.GetValue() {
+		schema.Enum = append(schema.Enum, value.GetNumber())
+		values = append(values, fmt.Sprintf("%d=%s", value.GetNumber(), value.GetName()))
+	}
+	if comment := description(info.Comments); comment != "" {
+		values = append([]string{comment}, values...)
+	}
+	schema.Description = strings.Join(values, "\n")
+	return schema, nil
+}
+
+// runtimeObjectSchema wraps the spec and status schemas with the standard
+// Kubernetes object fields.
+func (b *schemaBuilder) runtimeObjectSchema(spec string, status string) (*JSONSchemaProps, error) {
+	specSchema, err := b.messageSchema(spec, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	schema := &JSONSchemaProps{
+		Type:        "object",
+		Description: specSchema.Description,
+		Properties: map[string]*JSONSchemaProps{
+			"apiVersion": {Type: "string"},
+			"kind":       {Type: "string"},
+			"metadata":   {Type: "object"},
+			"spec":       specSchema,
+		},
+	}
+	specSchema.Description = ""
+	if status != "" {
+		statusSchema, err := b.messageSchema(status, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		// The fields added to the status by ResourceStatus in K8S_TYPE_TEMPLATE
+		if statusSchema.Properties == nil {
+			statusSchema.Properties = make(map[string]*JSONSchemaProps)
+		}
+		statusSchema.Properties["observedGeneration"] = &JSONSchemaProps{
+			Type:        "integer",
+			Format:      "int64",
+			Description: "The metadata.generation the status was last reconciled at",
+		}
+		statusSchema.Properties["conditions"] = &JSONSchemaProps{
+			Type:  "array",
+			Items: conditionSchema(),
+		}
+		schema.Properties["status"] = statusSchema
+	}
+	return schema, nil
+}
+
+// conditionSchema is the schema of the Condition type of CONDITIONS_TEMPLATE
+func conditionSchema() *JSONSchemaProps {
+	return &JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]*JSONSchemaProps{
+			"type":               {Type: "string"},
+			"status":             {Type: "string", Enum: []interface{}{"True", "False", "Unknown"}},
+			"observedGeneration": {Type: "integer", Format: "int64"},
+			"lastTransitionTime": {Type: "string", Format: "date-time"},
+			"reason":             {Type: "string"},
+			"message":            {Type: "string"},
+		},
+		Required: []string{"type", "status"},
+	}
+}
+
+func (c *controllerGenerator) generateCRDs() error {
+
+	locationMessageMap, err := c.getLocationMessage()
+	if err != nil {
+		return err
+	}
+	validations, err := c.fieldValidations()
+	if err != nil {
+		return err
+	}
+	defaults, err := c.fieldDefaults()
+	if err != nil {
+		return err
+	}
+	builder := newSchemaBuilder(c.registry, c.Opts.NativeTypes(), validations, defaults)
+	group := c.Opts.Group
+
+	var errs GeneratorErrors
+	crds := make(map[string]*customResourceDefinition)
+	filenames := make([]string, 0)
+	for _, filename := range c.Request.FileToGenerate {
+		proto := c.registry.File(filename)
+		for _, locationMessage := range locationMessageMap[filename] {
+			kind := locationMessage.Name
+			plural := locationMessage.Plural
+
+			spec := qualifiedName(proto.GetPackage(), locationMessage.Message.GetName())
+			status := locationMessage.StatusTypeName
+			schema, err := builder.runtimeObjectSchema(spec, status)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, locationMessage.Message.GetName(), err))
+				continue
+			}
+
+			version := customResourceDefVersion{
+				Name:   proto.GetPackage(),
+				Served: true,
+				Schema: customResourceSchema{OpenAPIV3Schema: schema},
+			}
+			if status != "" {
+				version.Subresources = &customResourceSubresrc{Status: &struct{}{}}
+			}
+
+			// Each proto package is a version of the same kind
+			crdFile := fmt.Sprintf("config/crd/%s_%s.yaml", group, plural)
+			crd, ok := crds[crdFile]
+			if !ok {
+				version.Storage = true
+				crd = &customResourceDefinition{
+					APIVersion: "apiextensions.k8s.io/v1",
+					Kind:       "CustomResourceDefinition",
+					Metadata:   customResourceMetadata{Name: plural + "." + group},
+					Spec: customResourceDefinitionSpec{
+						Group: group,
+						Names: customResourceNames{
+							Kind:       kind,
+							ListKind:   kind + "List",
+							Plural:     plural,
+							Singular:   locationMessage.Singular,
+							ShortNames: locationMessage.ShortNames,
+							Categories: locationMessage.Categories,
+						},
+						Scope: locationMessage.Scope,
+					},
+				}
+				crds[crdFile] = crd
+				filenames = append(filenames, crdFile)
+			} else if crd.Spec.Scope != locationMessage.Scope {
+				errs = append(errs, fmt.Errorf("%s: message %s: scope %s differs from the %s scope of other versions", filename, locationMessage.Message.GetName(), locationMessage.Scope, crd.Spec.Scope))
+				continue
+			}
+			crd.Spec.Versions = append(crd.Spec.Versions, version)
+		}
+	}
+
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		content, err := yaml.Marshal(crds[filename])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", filename, err))
+			continue
+		}
+		c.writeFile(filename, content)
+	}
+	return errs.errorOrNil()
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func (c *controllerGenerator) generateRBAC() error {
+
+	locationMessageMap, err := c.getLocationMessage()
+	if err != nil {
+		return err
+	}
+	rbac, err := gotemplate.New("RBAC").Funcs(template.FuncMap).Parse(template.RBAC_TEMPLATE)
+	if err != nil {
+		return err
+	}
+
+	var errs GeneratorErrors
+	generated := make(map[string]bool)
+	for _, filename := range c.Request.FileToGenerate {
+		for _, locationMessage := range locationMessageMap[filename] {
+			var tpl template.RBACOpts
+			tpl.Name = locationMessage.Name
+			tpl.Group = c.Opts.Group
+			tpl.Plural = locationMessage.Plural
+			tpl.StatusType = locationMessage.StatusType
+			tpl.ClusterScoped = locationMessage.Scope == template.SCOPE_CLUSTER
+			tpl.Owns = locationMessage.Owns
+
+			// Every version of a kind shares the same role
+			rbacFile := fmt.Sprintf("config/rbac/%s_%s_role.yaml", tpl.Group, tpl.Plural)
+			if generated[rbacFile] {
+				continue
+			}
+			generated[rbacFile] = true
+			if err := c.runTemplate(rbacFile, rbac, &tpl); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, locationMessage.Message.GetName(), err))
+			}
+		}
+	}
+
+	// Every controller of the process shares one lease
+	leaderElection, err := gotemplate.New("LeaderElection").Funcs(template.FuncMap).Parse(template.LEADER_ELECTION_RBAC_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	tpl := template.RBACOpts{Name: template.GroupGoName(c.Opts.Group), Group: c.Opts.Group, ControllerRuntime: c.Opts.ControllerRuntime()}
+	if err := c.runTemplate(fmt.Sprintf("config/rbac/%s_leader_election_role.yaml", c.Opts.Group), leaderElection, &tpl); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.errorOrNil()
+}
@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDescription(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    string
+	}{
+		{"", ""},
+		{" The spec\n", "The spec"},
+		{" +genclient\n +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object\n", ""},
+		{" How many\n +drekle:k8s:minimum=0\n replicas run\n", "How many\nreplicas run"},
+	}
+	for _, test := range tests {
+		if got := description(test.comment); got != test.want {
+			t.Errorf("description(%q) = %q, want %q", test.comment, got, test.want)
+		}
+	}
+}
+
+func TestMessageSchema(t *testing.T) {
+	r := testRegistry()
+	recursive := &JSONSchemaProps{Type: "object", PreserveUnknown: boolPtr(true)}
+	tests := []struct {
+		native   bool
+		property string
+		want     *JSONSchemaProps
+	}{
+		{false, "name", &JSONSchemaProps{Type: "string"}},
+		{false, "count", &JSONSchemaProps{Type: "integer", Format: "int32"}},
+		{false, "size", &JSONSchemaProps{Type: "integer", Minimum: float64Ptr(0)}},
+		{false, "ratio", &JSONSchemaProps{Type: "number", Format: "float"}},
+		{false, "tags", &JSONSchemaProps{Type: "array", Items: &JSONSchemaProps{Type: "string"}}},
+		{false, "labels", &JSONSchemaProps{Type: "object", AdditionalProperties: &JSONSchemaProps{Type: "integer", Format: "int64"}}},
+		{false, "color", &JSONSchemaProps{Type: "integer", Format: "int32", Enum: []interface{}{int32(0), int32(1)}, Description: "0=RED\n1=BLUE"}},
+		{false, "on", &JSONSchemaProps{Type: "boolean"}},
+		{false, "Choice", &JSONSchemaProps{
+			Type:          "object",
+			Properties:    map[string]*JSONSchemaProps{"ChoiceA": {Type: "string"}},
+			MaxProperties: int64Ptr(1),
+		}},
+		{false, "blob", &JSONSchemaProps{Type: "string", Format: "byte"}},
+		{false, "spec", recursive},
+		{true, "color", &JSONSchemaProps{Type: "string", Enum: []interface{}{"RED", "BLUE"}}},
+		{true, "choiceA", &JSONSchemaProps{Type: "string", Description: "At most one of choiceA may be set."}},
+		{true, "labels", &JSONSchemaProps{Type: "object", AdditionalProperties: &JSONSchemaProps{Type: "integer", Format: "int64"}}},
+		{true, "spec", recursive},
+	}
+	for _, test := range tests {
+		schema, err := newSchemaBuilder(r, test.native, nil, nil).messageSchema(".v1.Spec", make(map[string]bool))
+		if err != nil {
+			t.Fatalf("messageSchema error = %v", err)
+		}
+		if got := schema.Properties[test.property]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("native %v: property %s = %+v, want %+v", test.native, test.property, got, test.want)
+		}
+	}
+}
+
+func TestMessageSchemaAnnotations(t *testing.T) {
+	r := testRegistry()
+	validations := fieldValidations{
+		".v1.Spec": {
+			"name": {Required: true, MaxLength: int64Ptr(3)},
+			"tags": {MinItems: int64Ptr(1), Pattern: "^[a-z]+$"},
+		},
+	}
+	defaults := fieldDefaults{".v2.Legacy": {"replicas": {Literal: "1", Value: int64(1)}}}
+	tests := []struct {
+		native   bool
+		message  string
+		property string
+		want     *JSONSchemaProps
+		required []string
+	}{
+		{false, ".v1.Spec", "name", &JSONSchemaProps{Type: "string", MaxLength: int64Ptr(3)}, []string{"name"}},
+		{false, ".v1.Spec", "tags", &JSONSchemaProps{Type: "array", MinItems: int64Ptr(1), Items: &JSONSchemaProps{Type: "string", Pattern: "^[a-z]+$"}}, []string{"name"}},
+		{true, ".v1.Spec", "name", &JSONSchemaProps{Type: "string", MaxLength: int64Ptr(3)}, []string{"name"}},
+		// Only native types require the required proto2 fields
+		{false, ".v2.Legacy", "replicas", &JSONSchemaProps{Type: "integer", Format: "int32", Default: int64(1)}, nil},
+		{true, ".v2.Legacy", "replicas", &JSONSchemaProps{Type: "integer", Format: "int32", Default: int64(1)}, []string{"id"}},
+	}
+	for _, test := range tests {
+		schema, err := newSchemaBuilder(r, test.native, validations, defaults).messageSchema(test.message, make(map[string]bool))
+		if err != nil {
+			t.Fatalf("messageSchema error = %v", err)
+		}
+		if got := schema.Properties[test.property]; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("native %v: %s property %s = %+v, want %+v", test.native, test.message, test.property, got, test.want)
+		}
+		if !reflect.DeepEqual(schema.Required, test.required) {
+			t.Errorf("native %v: %s required = %v, want %v", test.native, test.message, schema.Required, test.required)
+		}
+	}
+}
+
+func TestMessageSchemaNotFound(t *testing.T) {
+	_, err := newSchemaBuilder(testRegistry(), false, nil, nil).messageSchema(".v1.Missing", make(map[string]bool))
+	if err == nil {
+		t.Errorf("messageSchema of a missing message succeeded")
+	}
+}
+
+func TestRuntimeObjectSchema(t *testing.T) {
+	schema, err := newSchemaBuilder(testRegistry(), true, nil, nil).runtimeObjectSchema(".v2.Legacy", ".v1.Spec")
+	if err != nil {
+		t.Fatalf("runtimeObjectSchema error = %v", err)
+	}
+	for _, property := range []string{"apiVersion", "kind", "metadata", "spec", "status"} {
+		if schema.Properties[property] == nil {
+			t.Errorf("no %s property", property)
+		}
+	}
+	status := schema.Properties["status"]
+	if status.Properties["observedGeneration"] == nil || !reflect.DeepEqual(status.Properties["conditions"].Items, conditionSchema()) {
+		t.Errorf("status lacks the observed generation and conditions: %+v", status.Properties)
+	}
+	if status.Properties["name"] == nil {
+		t.Errorf("status lacks the fields of the status message: %+v", status.Properties)
+	}
+
+	schema, err = newSchemaBuilder(testRegistry(), true, nil, nil).runtimeObjectSchema(".v2.Legacy", "")
+	if err != nil {
+		t.Fatalf("runtimeObjectSchema error = %v", err)
+	}
+	if _, ok := schema.Properties["status"]; ok {
+		t.Errorf("a kind without status has a status property")
+	}
+}
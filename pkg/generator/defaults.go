@@ -0,0 +1,336 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	gotemplate "text/template"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	gogen "github.com/golang/protobuf/protoc-gen-go/generator"
+
+	"github.com/drekle/protoc-gen-k8s/pkg/template"
+)
+
+// fieldDefault holds the default annotation of a field
+type fieldDefault struct {
+	// Literal is the default as an untyped Go constant, Value as a schema value
+	Literal string
+	Value   interface{}
+}
+
+// fieldDefaults maps fully qualified message names to the defaults of their fields,
+// keyed by field name
+type fieldDefaults map[string]map[string]*fieldDefault
+
+// get returns the default of the field, nil if it has none
+func (d fieldDefaults) get(message string, field string) *fieldDefault {
+	return d[message][field]
+}
+
+// fieldDefaults parses the default annotations of the fields of every local message
+func (c *controllerGenerator) fieldDefaults() (fieldDefaults, error) {
+	ret := make(fieldDefaults)
+	var errs GeneratorErrors
+	for _, filename := range c.registry.Dependencies(c.Request.FileToGenerate) {
+		file := c.registry.File(filename)
+		if !c.isLocalFile(file) {
+			continue
+		}
+		for _, info := range c.registry.FileMessages(file) {
+			name := qualifiedName(file.GetPackage(), info.Nested...)
+			for _, field := range info.Message.GetField() {
+				value, ok := annotationValue(strings.Split(info.FieldComments[field.GetName()], "\n"), template.DREKLE_DEFAULT_KEY)
+				if !ok {
+					continue
+				}
+				parsed, err := c.registry.parseFieldDefault(file, field, value, c.Opts.NativeTypes())
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: message %s: field %s: invalid %s annotation `%s`: %v", filename, strings.TrimPrefix(name, "."), field.GetName(), strings.TrimSuffix(template.DREKLE_DEFAULT_KEY, "="), value, err))
+					continue
+				}
+				if ret[name] == nil {
+					ret[name] = make(map[string]*fieldDefault)
+				}
+				ret[name][field.GetName()] = parsed
+			}
+		}
+	}
+	return ret, errs.errorOrNil()
+}
+
+// bitSize is the size of the Go type of a numeric field
+func bitSize(field *descriptor.FieldDescriptorProto) int {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return 32
+	}
+	return 64
+}
+
+// parseFieldDefault parses the default of a scalar or enum field. Enums default to
+// the name of a value, which native types serialize by name and golang/protobuf
+// messages by number. Only fields which tell unset from zero take a default, native
+// types hold a pointer to defaulted proto3 fields for it.
+func (r *registry) parseFieldDefault(file *descriptor.FileDescriptorProto, field *descriptor.FieldDescriptorProto, value string, native bool) (*fieldDefault, error) {
+	switch {
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return nil, fmt.Errorf("repeated fields and maps have no default")
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		return nil, fmt.Errorf("required fields are always set")
+	case field.OneofIndex != nil:
+		return nil, fmt.Errorf("oneof members have no default")
+	case file.GetSyntax() == "proto3" && !native:
+		return nil, fmt.Errorf("proto3 fields of golang/protobuf messages cannot tell a zero value from an unset one, use types=native or proto2")
+	}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return &fieldDefault{Literal: strconv.Quote(value), Value: value}, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldDefault{Literal: strconv.FormatBool(b), Value: b}, nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		info := r.Enum(field.GetTypeName())
+		if info == nil {
+			return nil, fmt.Errorf("enum `%s` not found", field.GetTypeName())
+		}
+		for _, enumValue := range info.Enum.GetValue() {
+			if enumValue.GetName() != value {
+				continue
+			}
+			if native {
+				return &fieldDefault{Literal: strconv.Quote(value), Value: value}, nil
+			}
+			return &fieldDefault{Literal: strconv.Itoa(int(enumValue.GetNumber())), Value: enumValue.GetNumber()}, nil
+		}
+		return nil, fmt.Errorf("%s is not a value of %s", value, strings.TrimPrefix(field.GetTypeName(), "."))
+	}
+	switch scalarKind(field) {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, bitSize(field))
+		if err != nil {
+			return nil, err
+		}
+		return &fieldDefault{Literal: strconv.FormatInt(n, 10), Value: n}, nil
+	case "unsigned":
+		n, err := strconv.ParseUint(value, 10, bitSize(field))
+		if err != nil {
+			return nil, err
+		}
+		return &fieldDefault{Literal: strconv.FormatUint(n, 10), Value: n}, nil
+	case "number":
+		f, err := strconv.ParseFloat(value, bitSize(field))
+		if err != nil {
+			return nil, err
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("JSON has no %s", value)
+		}
+		return &fieldDefault{Literal: strconv.FormatFloat(f, 'g', -1, 64), Value: f}, nil
+	}
+	return nil, fmt.Errorf("only scalar and enum fields have a default")
+}
+
+// defaulter renders the SetDefaults functions of the messages of a file
+type defaulter struct {
+	goTypes
+	defaults fieldDefaults
+	// needs holds the messages with defaulted fields, directly or in the messages
+	// they hold
+	needs   map[string]bool
+	file    *descriptor.FileDescriptorProto
+	imports map[string]string
+}
+
+// generateDefaults writes the SetDefaults functions next to the types of every
+// local proto file and a SetDefaults function for each kind
+func (c *controllerGenerator) generateDefaults() error {
+	native, err := c.nativeTypes()
+	if err != nil {
+		return err
+	}
+	defaults, err := c.fieldDefaults()
+	if err != nil {
+		return err
+	}
+	tpl, err := gotemplate.New("Defaults").Funcs(template.FuncMap).Parse(template.DEFAULTS_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	files := make([]*descriptor.FileDescriptorProto, 0)
+	messages := make([]*messageInfo, 0)
+	for _, filename := range c.registry.Dependencies(c.Request.FileToGenerate) {
+		if file := c.registry.File(filename); c.isLocalFile(file) {
+			files = append(files, file)
+			messages = append(messages, c.registry.FileMessages(file)...)
+		}
+	}
+	needs := make(map[string]bool)
+	for name := range defaults {
+		needs[name] = true
+	}
+	d := &defaulter{
+		goTypes:  goTypes{nativeTypes: native, native: c.Opts.NativeTypes()},
+		defaults: defaults,
+		needs:    holdingMessages(c.registry, messages, needs),
+	}
+
+	var errs GeneratorErrors
+	for _, file := range files {
+		defaultsFile, err := d.defaultsFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(defaultsFile.Kinds) == 0 && len(defaultsFile.Messages) == 0 {
+			continue
+		}
+		out := fmt.Sprintf("pkg/apis/%s/%s/%sDefaults.go", strings.Replace(c.Opts.Group, ".", "", -1), file.GetPackage(), strings.TrimSuffix(path.Base(file.GetName()), ".proto"))
+		if err := c.runTemplate(out, tpl, defaultsFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", file.GetName(), err))
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// defaultsFile renders the functions of the messages of the file which need them
+func (d *defaulter) defaultsFile(file *descriptor.FileDescriptorProto) (*template.DefaultsFile, error) {
+	d.file = file
+	d.imports = make(map[string]string)
+	ret := &template.DefaultsFile{
+		Package: file.GetPackage(),
+		Source:  file.GetName(),
+	}
+	var errs GeneratorErrors
+	code := make([]string, 0)
+	for _, info := range d.registry.FileMessages(file) {
+		name := qualifiedName(file.GetPackage(), info.Nested...)
+		if kind, ok := d.kinds[name]; ok {
+			defaults := template.KindDefaults{Name: kind}
+			if d.needs[name] {
+				defaults.Spec = d.goName(info)
+			}
+			ret.Kinds = append(ret.Kinds, defaults)
+		}
+		if !d.needs[name] {
+			continue
+		}
+		message, err := d.message(info)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ret.Messages = append(ret.Messages, message)
+		code = append(code, message.Statements...)
+	}
+	ret.Imports = usedImports(d.imports, code)
+	return ret, errs.errorOrNil()
+}
+
+// message renders the defaulting of each field of the message
+func (d *defaulter) message(info *messageInfo) (template.MessageDefaults, error) {
+	name := qualifiedName(info.File.GetPackage(), info.Nested...)
+	ret := template.MessageDefaults{Name: d.goName(info)}
+	names := newProtoNames(info, d.goPath(info))
+	var errs GeneratorErrors
+	for _, field := range info.Message.GetField() {
+		var statement string
+		var err error
+		fieldDefault := d.defaults.get(name, field.GetName())
+		valueField, isMap := d.registry.valueField(field)
+		switch {
+		case fieldDefault != nil:
+			statement, err = d.value(names, field, fieldDefault)
+		case d.needs[valueField.GetTypeName()]:
+			target := d.registry.Message(valueField.GetTypeName())
+			var setDefaults string
+			if setDefaults, err = d.qualify(d.file, target.File, "SetDefaults_"+d.goName(target), d.imports); err == nil {
+				statement = d.nested(names, field, isMap, setDefaults)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: message %s: field %s: %v", info.File.GetName(), strings.TrimPrefix(name, "."), field.GetName(), err))
+			continue
+		}
+		ret.Statements = append(ret.Statements, statement)
+	}
+	return ret, errs.errorOrNil()
+}
+
+// goField is the Go name of the field of the message
+func (d *defaulter) goField(names *protoNames, field *descriptor.FieldDescriptorProto) string {
+	if d.native {
+		return gogen.CamelCase(field.GetName())
+	}
+	return names.fields[field.GetName()]
+}
+
+// value renders the statement setting the field to its default when it is unset.
+// parseFieldDefault only accepts fields holding a pointer, so a set zero value is kept.
+func (d *defaulter) value(names *protoNames, field *descriptor.FieldDescriptorProto, fieldDefault *fieldDefault) (string, error) {
+	in := "in." + d.goField(names, field)
+	if !d.pointer(d.file, field) {
+		return "", fmt.Errorf("a default needs a field which tells unset from zero")
+	}
+	value := fieldDefault.Literal
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BOOL:
+	default:
+		// Untyped numbers would be an int or float64
+		goType, err := d.valueType(field)
+		if err != nil {
+			return "", err
+		}
+		value = fmt.Sprintf("%s(%s)", goType, value)
+	}
+	return fmt.Sprintf("if %s == nil {\nv := %s\n%s = &v\n}", in, value, in), nil
+}
+
+// valueType is the Go type of a defaulted field, ignoring its pointer. gogen
+// prefixes the enums declared in runtime objects with them.
+func (d *defaulter) valueType(field *descriptor.FieldDescriptorProto) (string, error) {
+	if field.GetType() != descriptor.FieldDescriptorProto_TYPE_ENUM || d.native {
+		return d.nativeTypes.valueType(d.file, field, d.imports)
+	}
+	info := d.registry.Enum(field.GetTypeName())
+	if info == nil {
+		return "", fmt.Errorf("enum `%s` not found", field.GetTypeName())
+	}
+	nested := append([]string{}, info.Nested...)
+	if _, ok := d.kinds[qualifiedName(info.File.GetPackage(), nested[0])]; ok && len(nested) > 1 {
+		nested[0] = fmt.Sprintf(INTERNAL_FORMAT, nested[0])
+	}
+	return d.qualify(d.file, info.File, gogen.CamelCaseSlice(nested), d.imports)
+}
+
+// nested renders the statement defaulting the messages held by the field
+func (d *defaulter) nested(names *protoNames, field *descriptor.FieldDescriptorProto, isMap bool, setDefaults string) string {
+	in := "in." + d.goField(names, field)
+	switch {
+	case field.OneofIndex != nil && !d.native:
+		oneof := names.oneofs[field.GetOneofIndex()]
+		wrapper := names.wrappers[field.GetName()]
+		return fmt.Sprintf("if v, ok := in.%s.(*%s); ok && v.%s != nil {\n%s(v.%s)\n}", oneof, wrapper, names.fields[field.GetName()], setDefaults, names.fields[field.GetName()])
+	case field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		return fmt.Sprintf("if %s != nil {\n%s(%s)\n}", in, setDefaults, in)
+	case !d.native:
+		return fmt.Sprintf("for _, v := range %s {\nif v != nil {\n%s(v)\n}\n}", in, setDefaults)
+	case isMap:
+		// Native maps hold the messages themselves, which are not addressable
+		return fmt.Sprintf("for k, v := range %s {\n%s(&v)\n%s[k] = v\n}", in, setDefaults, in)
+	}
+	return fmt.Sprintf("for i := range %s {\n%s(&%s[i])\n}", in, setDefaults, in)
+}
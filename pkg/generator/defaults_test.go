@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldDefault(t *testing.T) {
+	r := testRegistry()
+	tests := []struct {
+		message string
+		field   string
+		value   string
+		native  bool
+		want    *fieldDefault
+		// err is a part of the expected error, empty when parsing succeeds
+		err string
+	}{
+		{message: ".v1.Spec", field: "name", value: "a b", native: true, want: &fieldDefault{Literal: `"a b"`, Value: "a b"}},
+		{message: ".v1.Spec", field: "count", value: "-3", native: true, want: &fieldDefault{Literal: "-3", Value: int64(-3)}},
+		{message: ".v1.Spec", field: "size", value: "3", native: true, want: &fieldDefault{Literal: "3", Value: uint64(3)}},
+		{message: ".v1.Spec", field: "ratio", value: "0.5", native: true, want: &fieldDefault{Literal: "0.5", Value: 0.5}},
+		{message: ".v1.Spec", field: "on", value: "true", native: true, want: &fieldDefault{Literal: "true", Value: true}},
+		{message: ".v1.Spec", field: "color", value: "BLUE", native: true, want: &fieldDefault{Literal: `"BLUE"`, Value: "BLUE"}},
+		{message: ".v2.Legacy", field: "replicas", value: "0", want: &fieldDefault{Literal: "0", Value: int64(0)}},
+		{message: ".v2.Legacy", field: "flag", value: "false", want: &fieldDefault{Literal: "false", Value: false}},
+		{message: ".v2.Legacy", field: "flag", value: "false", native: true, want: &fieldDefault{Literal: "false", Value: false}},
+		{message: ".v1.Spec", field: "count", value: "1", err: "cannot tell a zero value from an unset one"},
+		{message: ".v1.Spec", field: "on", value: "true", err: "cannot tell a zero value from an unset one"},
+		{message: ".v2.Legacy", field: "id", value: "a", native: true, err: "required fields are always set"},
+		{message: ".v1.Spec", field: "tags", value: "a", native: true, err: "repeated fields and maps have no default"},
+		{message: ".v1.Spec", field: "labels", value: "a", native: true, err: "repeated fields and maps have no default"},
+		{message: ".v1.Spec", field: "choice_a", value: "a", native: true, err: "oneof members have no default"},
+		{message: ".v1.Spec", field: "spec", value: "{}", native: true, err: "only scalar and enum fields have a default"},
+		{message: ".v1.Spec", field: "blob", value: "a", native: true, err: "only scalar and enum fields have a default"},
+		{message: ".v1.Spec", field: "color", value: "GREEN", native: true, err: "GREEN is not a value of v1.Color"},
+		{message: ".v1.Spec", field: "on", value: "yes", native: true, err: "invalid syntax"},
+		{message: ".v1.Spec", field: "count", value: "1.5", native: true, err: "invalid syntax"},
+		{message: ".v1.Spec", field: "count", value: "3000000000", native: true, err: "value out of range"},
+		{message: ".v1.Spec", field: "size", value: "-1", native: true, err: "invalid syntax"},
+		{message: ".v1.Spec", field: "ratio", value: "1e40", native: true, err: "value out of range"},
+		{message: ".v1.Spec", field: "ratio", value: "NaN", native: true, err: "JSON has no NaN"},
+	}
+	for _, test := range tests {
+		name := test.message + "." + test.field + "=" + test.value
+		if test.native {
+			name += " native"
+		}
+		t.Run(name, func(t *testing.T) {
+			info := r.Message(test.message)
+			parsed, err := r.parseFieldDefault(info.File, testMessageField(r, test.message, test.field), test.value, test.native)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("parseFieldDefault error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFieldDefault error = %v", err)
+			}
+			if !reflect.DeepEqual(parsed, test.want) {
+				t.Errorf("parseFieldDefault = %+v, want %+v", parsed, test.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldDefaultEnumNumber(t *testing.T) {
+	r := testRegistry()
+	// golang/protobuf messages hold enums by number, only proto2 ones take a default
+	r.Message(".v1.Spec").File.Syntax = nil
+	parsed, err := r.parseFieldDefault(r.Message(".v1.Spec").File, testMessageField(r, ".v1.Spec", "color"), "BLUE", false)
+	if err != nil {
+		t.Fatalf("parseFieldDefault error = %v", err)
+	}
+	want := &fieldDefault{Literal: "1", Value: int32(1)}
+	if !reflect.DeepEqual(parsed, want) {
+		t.Errorf("parseFieldDefault = %+v, want %+v", parsed, want)
+	}
+}
+
+func TestDefaulterValue(t *testing.T) {
+	r := testRegistry()
+	native := testNativeTypes(r)
+	for _, name := range []string{"name", "count", "color", "on"} {
+		native.defaulted[testMessageField(r, ".v1.Spec", name)] = true
+	}
+	tests := []struct {
+		native  bool
+		message string
+		field   string
+		value   *fieldDefault
+		want    string
+		// err is a part of the expected error, empty when rendering succeeds
+		err string
+	}{
+		{
+			native:  true,
+			message: ".v1.Spec",
+			field:   "name",
+			value:   &fieldDefault{Literal: `"a"`},
+			want:    "if in.Name == nil {\nv := \"a\"\nin.Name = &v\n}",
+		},
+		{
+			native:  true,
+			message: ".v1.Spec",
+			field:   "count",
+			value:   &fieldDefault{Literal: "3"},
+			want:    "if in.Count == nil {\nv := int32(3)\nin.Count = &v\n}",
+		},
+		{
+			native:  true,
+			message: ".v1.Spec",
+			field:   "color",
+			value:   &fieldDefault{Literal: `"BLUE"`},
+			want:    "if in.Color == nil {\nv := Color(\"BLUE\")\nin.Color = &v\n}",
+		},
+		{
+			native:  true,
+			message: ".v1.Spec",
+			field:   "on",
+			value:   &fieldDefault{Literal: "false"},
+			want:    "if in.On == nil {\nv := false\nin.On = &v\n}",
+		},
+		{
+			message: ".v2.Legacy",
+			field:   "replicas",
+			value:   &fieldDefault{Literal: "0"},
+			want:    "if in.Replicas == nil {\nv := int32(0)\nin.Replicas = &v\n}",
+		},
+		{
+			native:  true,
+			message: ".v1.Spec",
+			field:   "size",
+			value:   &fieldDefault{Literal: "1"},
+			err:     "a default needs a field which tells unset from zero",
+		},
+	}
+	for _, test := range tests {
+		info := r.Message(test.message)
+		d := &defaulter{goTypes: goTypes{nativeTypes: native, native: test.native}, file: info.File, imports: make(map[string]string)}
+		got, err := d.value(newProtoNames(info, d.goPath(info)), testMessageField(r, test.message, test.field), test.value)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: value error = %v, want %q", test.field, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: value error = %v", test.field, err)
+		}
+		if got != test.want {
+			t.Errorf("native %v: %s:\n%s\nwant\n%s", test.native, test.field, got, test.want)
+		}
+	}
+}
+
+func TestDefaulterNested(t *testing.T) {
+	r := testRegistry()
+	info := r.Message(".v1.Spec")
+	// The statements only depend on the shape of the field, so the lists and maps of
+	// scalars stand in for those of messages
+	tests := []struct {
+		native bool
+		field  string
+		want   string
+	}{
+		{true, "spec", "if in.Spec != nil {\nSetDefaults_Spec(in.Spec)\n}"},
+		{false, "spec", "if in.Spec != nil {\nSetDefaults_Spec(in.Spec)\n}"},
+		{true, "labels", "for k, v := range in.Labels {\nSetDefaults_Spec(&v)\nin.Labels[k] = v\n}"},
+		{false, "labels", "for _, v := range in.Labels {\nif v != nil {\nSetDefaults_Spec(v)\n}\n}"},
+		{true, "tags", "for i := range in.Tags {\nSetDefaults_Spec(&in.Tags[i])\n}"},
+		{false, "choice_a", "if v, ok := in.Choice.(*Spec_ChoiceA); ok && v.ChoiceA != nil {\nSetDefaults_Spec(v.ChoiceA)\n}"},
+	}
+	for _, test := range tests {
+		d := &defaulter{goTypes: goTypes{nativeTypes: testNativeTypes(r), native: test.native}, file: info.File}
+		field := testMessageField(r, ".v1.Spec", test.field)
+		_, isMap := r.valueField(field)
+		if got := d.nested(newProtoNames(info, d.goPath(info)), field, isMap, "SetDefaults_Spec"); got != test.want {
+			t.Errorf("native %v: %s:\n%s\nwant\n%s", test.native, test.field, got, test.want)
+		}
+	}
+}
+
+func TestDefaulterMessage(t *testing.T) {
+	r := testRegistry()
+	native := testNativeTypes(r)
+	native.defaulted[testMessageField(r, ".v1.Spec", "count")] = true
+	info := r.Message(".v1.Spec")
+	d := &defaulter{
+		goTypes:  goTypes{nativeTypes: native, native: true},
+		defaults: fieldDefaults{".v1.Spec": {"count": {Literal: "1", Value: int64(1)}}},
+		needs:    holdingMessages(r, r.FileMessages(info.File), map[string]bool{".v1.Spec": true}),
+		file:     info.File,
+		imports:  make(map[string]string),
+	}
+	message, err := d.message(info)
+	if err != nil {
+		t.Fatalf("message error = %v", err)
+	}
+	want := []string{
+		"if in.Count == nil {\nv := int32(1)\nin.Count = &v\n}",
+		"if in.Spec != nil {\nSetDefaults_Spec(in.Spec)\n}",
+	}
+	if message.Name != "Spec" || !reflect.DeepEqual(message.Statements, want) {
+		t.Errorf("message = %s %q, want Spec %q", message.Name, message.Statements, want)
+	}
+}
@@ -5,11 +5,16 @@ import (
 	"bytes"
 	"fmt"
 	"go/format"
+	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	gotemplate "text/template"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	gogen "github.com/golang/protobuf/protoc-gen-go/generator"
 	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
@@ -17,16 +22,21 @@ import (
 	"github.com/drekle/protoc-gen-k8s/pkg/template"
 )
 
+// EXAMPLE_REPO is the module path used when neither the `module` option nor a
+// go_package file option is provided.
 var EXAMPLE_REPO = "www.github.com/drekle/k8sexample"
 
 type controllerGenerator struct {
 	Request  *plugin.CodeGeneratorRequest
 	Response *plugin.CodeGeneratorResponse
-	Opts     map[string]string
+	Opts     *Options
+	// RepoURL is the Go module path of the generated project
+	RepoURL string
+	// registry indexes the request descriptors by name
+	registry *registry
 }
 
 const (
-	GROUP_OPTION    = "group"
 	INTERNAL_FORMAT = "XXX_%s"
 )
 
@@ -34,33 +44,120 @@ type LocationMessage struct {
 	Location *descriptor.SourceCodeInfo_Location
 	Message  *descriptor.DescriptorProto
 	Comments []string
+	// Name is the Kubernetes kind of the message
+	Name string
+	// StatusMessage is the message referenced by the +drekle:k8s:status annotation
+	StatusMessage *descriptor.DescriptorProto
+	// StatusType is the Go type generated for StatusMessage
+	StatusType string
+	// StatusFile is the proto file declaring StatusMessage
+	StatusFile string
+	// StatusTypeName is the fully qualified proto name of StatusMessage
+	StatusTypeName string
+	// Scope is either template.SCOPE_NAMESPACED or template.SCOPE_CLUSTER
+	Scope string
+	// Plural and Singular are the lower case resource names of the kind
+	Plural     string
+	Singular   string
+	ShortNames []string
+	Categories []string
+	// Defaults are the controller defaults set with annotations
+	Defaults template.ControllerDefaults
+	// Owns are the secondary resources created for each object
+	Owns []template.OwnedResource
 }
 
-func validateOptions(opts map[string]string) error {
-	for k, _ := range opts {
-		found := false
-		for _, knownOption := range []string{GROUP_OPTION} {
-			if k == knownOption {
-				found = true
+func NewControllerGenerator(request *plugin.CodeGeneratorRequest, response *plugin.CodeGeneratorResponse, opts *Options) (*controllerGenerator, error) {
+	repoURL, err := resolveRepoURL(request, opts)
+	if err != nil {
+		return nil, err
+	}
+	// This generator will need to know the output directory
+	return &controllerGenerator{
+		Request:  request,
+		Response: response,
+		Opts:     opts,
+		RepoURL:  repoURL,
+		// The registry folds the synthetic oneofs of the descriptors it indexes, the
+		// request keeps the descriptors protoc sent
+		registry: newRegistry(cloneFiles(request.ProtoFile)),
+	}, nil
+}
+
+// resolveRepoURL determines the module path of the generated project. The
+// `module` option takes precedence over the go_package of the files to generate.
+func resolveRepoURL(request *plugin.CodeGeneratorRequest, opts *Options) (string, error) {
+	if opts.Module != "" {
+		return opts.Module, nil
+	}
+	for _, filename := range request.FileToGenerate {
+		for _, proto := range request.ProtoFile {
+			if proto.GetName() != filename {
+				continue
+			}
+			goPackage := proto.GetOptions().GetGoPackage()
+			if goPackage == "" {
+				continue
+			}
+			// go_package may carry an explicit package name: "path;name"
+			if i := strings.Index(goPackage, ";"); i >= 0 {
+				goPackage = goPackage[:i]
 			}
+			if err := validateImportPath(goPackage); err != nil {
+				return "", fmt.Errorf("%s: invalid go_package: %v", filename, err)
+			}
+			return goPackage, nil
+		}
+	}
+	return EXAMPLE_REPO, nil
+}
+
+// validateImportPath checks that the path is a legal Go import path, following
+// the rules the go command applies to module paths.
+func validateImportPath(importPath string) error {
+	if importPath == "" {
+		return fmt.Errorf("empty import path")
+	}
+	if strings.HasPrefix(importPath, "/") || strings.HasSuffix(importPath, "/") {
+		return fmt.Errorf("import path `%s` must not begin or end with a slash", importPath)
+	}
+	for _, elem := range strings.Split(importPath, "/") {
+		if elem == "" {
+			return fmt.Errorf("import path `%s` has an empty path element", importPath)
 		}
-		if !found {
-			return fmt.Errorf("Unknown Option `%s`", k)
+		if elem[0] == '.' || elem[len(elem)-1] == '.' {
+			return fmt.Errorf("import path `%s` has an element beginning or ending with a dot", importPath)
+		}
+		for _, r := range elem {
+			switch {
+			case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			case r == '-', r == '.', r == '_', r == '~':
+			default:
+				return fmt.Errorf("import path `%s` contains invalid character %q", importPath, r)
+			}
 		}
 	}
 	return nil
 }
 
-func NewControllerGenerator(request *plugin.CodeGeneratorRequest, response *plugin.CodeGeneratorResponse, opts map[string]string) (*controllerGenerator, error) {
-	if err := validateOptions(opts); err != nil {
-		return nil, err
+// GeneratorErrors collects every failure encountered during generation so they
+// can be reported together through CodeGeneratorResponse.Error.
+type GeneratorErrors []error
+
+func (e GeneratorErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
 	}
-	// This generator will need to know the output directory
-	return &controllerGenerator{
-		request,
-		response,
-		opts,
-	}, nil
+	return strings.Join(messages, "\n")
+}
+
+// errorOrNil returns nil when no errors were collected
+func (e GeneratorErrors) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
 }
 
 func (c *controllerGenerator) GenerateCode() error {
@@ -68,116 +165,195 @@ func (c *controllerGenerator) GenerateCode() error {
 	files := make([]*plugin.CodeGeneratorResponse_File, 0)
 	c.Response.File = files
 
-	{
-		err := c.generateController()
-		if err != nil {
-			return err
-		}
+	// Invalid runtime object annotations would fail every step alike
+	if _, err := c.getLocationMessage(); err != nil {
+		return err
 	}
-	{
-		err := c.generateCobra()
-		if err != nil {
-			return err
-		}
+	if _, err := c.fieldValidations(); err != nil {
+		return err
 	}
-	{
-		err := c.generateSignals()
-		if err != nil {
-			return err
-		}
+	if _, err := c.fieldDefaults(); err != nil {
+		return err
 	}
-	{
-		err := c.generateKubeAPI()
-		if err != nil {
-			return err
-		}
+	if err := c.protobufFields(); err != nil {
+		return err
 	}
-	{
-		err := c.generateGoGen()
-		if err != nil {
-			return err
+
+	// Every step is run so that all failures are reported at once
+	var errs GeneratorErrors
+	for _, step := range []struct {
+		name     string
+		generate func() error
+	}{
+		{STEP_CONTROLLER, c.generateController},
+		{STEP_COBRA, c.generateCobra},
+		{STEP_SIGNALS, c.generateSignals},
+		{STEP_SERVER, c.generateServer},
+		{STEP_KUBEAPI, c.generateKubeAPI},
+		{STEP_CRD, c.generateCRDs},
+		{STEP_RBAC, c.generateRBAC},
+		{STEP_GOGEN, c.generateGoGen},
+		{STEP_GOMOD, c.generateGoMod},
+		{STEP_HACK, c.generateHack},
+		{STEP_MAKEFILE, c.generateMakefile},
+	} {
+		if c.Opts.Skipped(step.name) {
+			continue
+		}
+		if err := step.generate(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	{
-		err := c.generateGoMod()
-		if err != nil {
-			return err
+	return errs.errorOrNil()
+}
+
+func (c *controllerGenerator) generateGoGen() error {
+
+	locationMessageMap, err := c.getLocationMessage()
+	if err != nil {
+		return err
+	}
+	group := strings.Replace(c.Opts.Group, ".", "", -1)
+
+	// Native API types leave the messages to a package of their own, next to the
+	// API packages they would otherwise collide with
+	goPackageDir := path.Join("pkg", "apis", group)
+	if c.Opts.NativeTypes() {
+		goPackageDir = path.Join("pkg", "proto")
+	}
+
+	// gogen runs on a copy of the request so that renaming the runtime objects does
+	// not leak into the descriptors used by the other steps. Its registry folds the
+	// synthetic oneofs of the copy, which protoc-gen-go v1.3 would generate as oneofs.
+	newReq := proto.Clone(c.Request).(*plugin.CodeGeneratorRequest)
+	genRegistry := newRegistry(newReq.ProtoFile)
+	renamed := make(map[string]string)
+	for _, filename := range c.Request.FileToGenerate {
+		if c.Opts.NativeTypes() {
+			break
+		}
+		for _, locationMessage := range locationMessageMap[filename] {
+			file := c.registry.File(filename)
+			oldName := qualifiedName(file.GetPackage(), locationMessage.Message.GetName())
+			newName := fmt.Sprintf(INTERNAL_FORMAT, locationMessage.Message.GetName())
+			genRegistry.Message(oldName).Message.Name = &newName
+			renamed[oldName] = qualifiedName(file.GetPackage(), newName)
 		}
 	}
-	{
-		err := c.generateHack()
-		if err != nil {
-			return err
+
+	// Imported files declared in this project, e.g. a shared common.proto, are
+	// generated next to the files which import them
+	genFiles := make([]string, 0)
+	for _, filename := range c.registry.Dependencies(c.Request.FileToGenerate) {
+		file := genRegistry.File(filename)
+		if !c.isLocalFile(file) {
+			continue
+		}
+		genFiles = append(genFiles, filename)
+		if file.Options == nil {
+			file.Options = &descriptor.FileOptions{}
 		}
+		goPackage := fmt.Sprintf("%s/%s/%s;%s", c.RepoURL, goPackageDir, file.GetPackage(), file.GetPackage())
+		file.Options.GoPackage = &goPackage
 	}
-	{
-		err := c.generateMakefile()
-		if err != nil {
-			return err
+
+	for _, file := range newReq.ProtoFile {
+		// We must remove all leading comments as to not forward runtime object comments to the kubernetes generator
+		for _, location := range file.GetSourceCodeInfo().GetLocation() {
+			location.LeadingComments = nil
+		}
+		// References to renamed messages, and the messages nested in them, must follow the rename
+		for _, message := range file.GetMessageType() {
+			renameFieldTypes(message, renamed)
 		}
 	}
 
+	for _, genFile := range genFiles {
+		file := genRegistry.File(genFile)
+		genReq := *newReq
+		genReq.FileToGenerate = []string{genFile}
+		// Run the standard gogen to generate the internal types
+		g := gogen.New()
+		g.Request = &genReq
+		g.WrapTypes()
+		g.SetPackageNames()
+		g.BuildTypeNameMap()
+		g.GenerateAllFiles()
+		for _, f := range g.Response.File {
+			//Override the output file
+			newPath := path.Join(goPackageDir, file.GetPackage(), path.Base(f.GetName()))
+			c.writeFile(newPath, []byte(f.GetContent()))
+		}
+	}
 	return nil
 }
 
-func (c *controllerGenerator) generateGoGen() error {
+// apiPackages lists the local packages of the files in order
+func (c *controllerGenerator) apiPackages(filenames []string) []string {
+	packages := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, filename := range filenames {
+		file := c.registry.File(filename)
+		if seen[file.GetPackage()] || !c.isLocalFile(file) {
+			continue
+		}
+		seen[file.GetPackage()] = true
+		packages = append(packages, file.GetPackage())
+	}
+	return packages
+}
 
-	{
-		for index, genFile := range c.Request.FileToGenerate {
-			proto := c.Request.ProtoFile[index]
-			group := c.Opts[GROUP_OPTION]
-			group = strings.Replace(group, ".", "", -1)
-
-			newReq := plugin.CodeGeneratorRequest(*c.Request)
-			// We must remove all leading comments as to not forward runtime object comments to the kubernetes generator
-			for index, _ := range newReq.FileToGenerate {
-				proto := newReq.ProtoFile[index]
-				desc := proto.GetSourceCodeInfo()
-				locations := desc.GetLocation()
-				for _, location := range locations {
-					comments := strings.Split(location.GetLeadingComments(), "\n")
-					for _, comment := range comments {
-						if strings.Contains(comment, "k8s.io/apimachinery/pkg/runtime.Object") {
-							message := proto.GetMessageType()[location.GetPath()[1]]
-							newName := fmt.Sprintf(INTERNAL_FORMAT, message.GetName())
-							message.Name = &newName
-							proto.GetMessageType()[location.GetPath()[1]] = message
-						}
-					}
-					location.LeadingComments = nil
-				}
-			}
+// isLocalFile reports whether Go code for the file belongs in the generated project
+// rather than in an existing Go package such as the well known types.
+func (c *controllerGenerator) isLocalFile(file *descriptor.FileDescriptorProto) bool {
+	for _, filename := range c.Request.FileToGenerate {
+		if filename == file.GetName() {
+			return true
+		}
+	}
+	goPackage := file.GetOptions().GetGoPackage()
+	return goPackage == "" || goPackage == c.RepoURL || strings.HasPrefix(goPackage, c.RepoURL+"/")
+}
 
-			newReq.FileToGenerate = []string{genFile}
-			// Run the standard gogen to generate the internal types
-			g := gogen.New()
-			g.Request = &newReq
-			g.WrapTypes()
-			g.SetPackageNames()
-			g.BuildTypeNameMap()
-			g.GenerateAllFiles()
-			for _, f := range g.Response.File {
-				//Override the output file
-				newPath := path.Join("pkg", "apis", group, proto.GetPackage(), path.Base(f.GetName()))
-				println(newPath)
-
-				c.Response.File = append(c.Response.File, &plugin.CodeGeneratorResponse_File{
-					Name:    &newPath,
-					Content: f.Content,
-				})
+// renameFieldTypes rewrites the field type references of the message according to
+// the map of old to new fully qualified names.
+func renameFieldTypes(message *descriptor.DescriptorProto, renamed map[string]string) {
+	for _, field := range message.GetField() {
+		for oldName, newName := range renamed {
+			typeName := field.GetTypeName()
+			if typeName == oldName || strings.HasPrefix(typeName, oldName+".") {
+				typeName = newName + strings.TrimPrefix(typeName, oldName)
+				field.TypeName = &typeName
 			}
 		}
 	}
-	return nil
+	for _, nested := range message.GetNestedType() {
+		renameFieldTypes(nested, renamed)
+	}
 }
 
 func (c *controllerGenerator) generateHack() error {
 
-	group := c.Opts[GROUP_OPTION]
+	group := c.Opts.Group
 	tpl := &template.ProtoMessage{
 		Group:   strings.Replace(group, ".", "", -1),
-		RepoURL: EXAMPLE_REPO,
-		Package: "v1",
+		RepoURL: c.RepoURL,
+		// code-generator takes the comma separated packages of the group
+		Package:           strings.Join(c.apiPackages(c.Request.FileToGenerate), ","),
+		ControllerRuntime: c.Opts.ControllerRuntime(),
+	}
+	if c.Opts.NativeTypes() {
+		kindPackages := make(map[string]bool)
+		for _, pkg := range c.apiPackages(c.Request.FileToGenerate) {
+			kindPackages[pkg] = true
+		}
+		nativePackages := make([]string, 0)
+		for _, pkg := range c.apiPackages(c.registry.Dependencies(c.Request.FileToGenerate)) {
+			if !kindPackages[pkg] {
+				nativePackages = append(nativePackages, pkg)
+			}
+		}
+		tpl.NativePackages = strings.Join(nativePackages, ",")
 	}
 	{
 		hack, err := gotemplate.New("k8s-hack").Funcs(template.FuncMap).Parse(template.K8S_HACK_TEMPLATE)
@@ -218,7 +394,9 @@ func (c *controllerGenerator) generateHack() error {
 func (c *controllerGenerator) generateGoMod() error {
 
 	var tpl template.TemplateOpts
-	tpl.RepoURL = EXAMPLE_REPO
+	tpl.RepoURL = c.RepoURL
+	tpl.ControllerRuntime = c.Opts.ControllerRuntime()
+	tpl.NativeTypes = c.Opts.NativeTypes()
 	gomod, err := gotemplate.New("GoMod").Funcs(template.FuncMap).Parse(template.GOMOD_TEMPLATE)
 	if err != nil {
 		return err
@@ -232,49 +410,167 @@ func (c *controllerGenerator) generateGoMod() error {
 }
 
 func (c *controllerGenerator) generateController() error {
+	if c.Opts.ControllerRuntime() {
+		return c.generateControllerRuntime()
+	}
+
+	locationMessageMap, err := c.getLocationMessage()
+	if err != nil {
+		return err
+	}
+
+	k8stpl, err := gotemplate.New("K8s-Controller").Funcs(template.FuncMap).Parse(template.ControllerTemplate)
+	if err != nil {
+		return err
+	}
+	entrytpl, err := gotemplate.New("K8s-Entrypoint").Funcs(template.FuncMap).Parse(template.ControllerEntrypoint)
+	if err != nil {
+		return err
+	}
 
-	locationMessageMap := c.getLocationMessage()
-	group := c.Opts[GROUP_OPTION]
+	runtpl, err := gotemplate.New("K8s-Run").Funcs(template.FuncMap).Parse(template.ControllerRunTemplate)
+	if err != nil {
+		return err
+	}
+	reconcilertpl, err := gotemplate.New("K8s-Reconciler").Funcs(template.FuncMap).Parse(template.ReconcilerTemplate)
+	if err != nil {
+		return err
+	}
+	managertpl, err := gotemplate.New("K8s-Manager").Funcs(template.FuncMap).Parse(template.MANAGER_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	finalizertpl, err := gotemplate.New("K8s-Finalizer").Parse(template.ControllerFinalizerTemplate)
+	if err != nil {
+		return err
+	}
 
-	for index, filename := range c.Request.FileToGenerate {
-		proto := c.Request.ProtoFile[index]
+	var errs GeneratorErrors
+	runOpts := template.ControllerRunOpts{RepoURL: c.RepoURL}
+	for _, filename := range c.Request.FileToGenerate {
+		proto := c.registry.File(filename)
 		locationMessages := locationMessageMap[filename]
 
-		var k8stypes template.ProtoFile
-		k8stypes.Package = proto.GetPackage()
-		k8stypes.Messages = make([]*template.ProtoMessage, 0)
-		k8stypes.Group = strings.Replace(group, ".", "", -1)
-		k8stypes.RepoURL = EXAMPLE_REPO
 		for _, locationMessage := range locationMessages {
-			var tpl template.TemplateOpts
-			tpl.Name = locationMessage.Message.GetName()
-			tpl.Package = proto.GetPackage()
-			tpl.RepoURL = EXAMPLE_REPO
-			tpl.Group = strings.Replace(group, ".", "", -1)
-			tpl.RuntimeType = locationMessage.Message.GetName()
-
-			k8stpl, err := gotemplate.New("K8s-Controller").Funcs(template.FuncMap).Parse(template.ControllerTemplate)
-			if err != nil {
-				return err
+			tpl := c.templateOpts(proto, locationMessage)
+			// A kind served by several versions gets a single controller
+			if controllerDeclared(runOpts.Controllers, tpl.Name) {
+				continue
 			}
-			filename := fmt.Sprintf("pkg/controller/%sController.go", tpl.Name)
-			err = c.runTemplate(filename, k8stpl, &tpl)
-			if err != nil {
-				return err
+			runOpts.Controllers = append(runOpts.Controllers, tpl)
+
+			controllerFile := fmt.Sprintf("pkg/controller/%sController.go", tpl.Name)
+			if err := c.runTemplate(controllerFile, k8stpl, &tpl); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, locationMessage.Message.GetName(), err))
+			}
+			reconcilerFile := fmt.Sprintf("pkg/controller/%sReconciler.go", tpl.Name)
+			if err := c.runScaffold(reconcilerFile, reconcilertpl, &tpl); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, locationMessage.Message.GetName(), err))
+			}
+			entrypointFile := fmt.Sprintf("pkg/controller/%sEntrypoint.go", tpl.Name)
+			if err := c.runTemplate(entrypointFile, entrytpl, &tpl); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, locationMessage.Message.GetName(), err))
 			}
+		}
+	}
+	if err := c.runTemplate("pkg/controller/run.go", runtpl, &runOpts); err != nil {
+		errs = append(errs, err)
+	}
+	managerOpts := template.ManagerOpts{Name: template.GroupGoName(c.Opts.Group), RepoURL: c.RepoURL}
+	if err := c.runTemplate("pkg/manager/manager.go", managertpl, &managerOpts); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.runTemplate("pkg/controller/finalizer.go", finalizertpl, nil); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.errorOrNil()
+}
 
-			entrytpl, err := gotemplate.New("K8s-Entrypoint").Funcs(template.FuncMap).Parse(template.ControllerEntrypoint)
-			if err != nil {
-				return err
+// generateControllerRuntime writes a controller-runtime Reconciler per kind and the
+// setup of every Reconciler with a manager
+func (c *controllerGenerator) generateControllerRuntime() error {
+
+	locationMessageMap, err := c.getLocationMessage()
+	if err != nil {
+		return err
+	}
+
+	reconcilertpl, err := gotemplate.New("CR-Controller").Funcs(template.FuncMap).Parse(template.CR_CONTROLLER_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	handlertpl, err := gotemplate.New("CR-Handler").Funcs(template.FuncMap).Parse(template.CR_HANDLER_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	setuptpl, err := gotemplate.New("CR-Setup").Funcs(template.FuncMap).Parse(template.CR_SETUP_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	finalizertpl, err := gotemplate.New("K8s-Finalizer").Parse(template.ControllerFinalizerTemplate)
+	if err != nil {
+		return err
+	}
+
+	var errs GeneratorErrors
+	runOpts := template.ControllerRunOpts{RepoURL: c.RepoURL}
+	for _, filename := range c.Request.FileToGenerate {
+		proto := c.registry.File(filename)
+		for _, locationMessage := range locationMessageMap[filename] {
+			tpl := c.templateOpts(proto, locationMessage)
+			// A kind served by several versions gets a single controller
+			if controllerDeclared(runOpts.Controllers, tpl.Name) {
+				continue
 			}
-			filename = fmt.Sprintf("pkg/controller/%sEntrypoint.go", tpl.Name)
-			err = c.runTemplate(filename, entrytpl, &tpl)
-			if err != nil {
-				return err
+			runOpts.Controllers = append(runOpts.Controllers, tpl)
+
+			reconcilerFile := fmt.Sprintf("pkg/controller/%sController.go", tpl.Name)
+			if err := c.runTemplate(reconcilerFile, reconcilertpl, &tpl); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, locationMessage.Message.GetName(), err))
+			}
+			handlerFile := fmt.Sprintf("pkg/controller/%sHandler.go", tpl.Name)
+			if err := c.runScaffold(handlerFile, handlertpl, &tpl); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, locationMessage.Message.GetName(), err))
 			}
 		}
 	}
-	return nil
+	if err := c.runTemplate("pkg/controller/setup.go", setuptpl, &runOpts); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.runTemplate("pkg/controller/finalizer.go", finalizertpl, nil); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.errorOrNil()
+}
+
+// templateOpts describes the controller of a runtime object
+func (c *controllerGenerator) templateOpts(proto *descriptor.FileDescriptorProto, locationMessage *LocationMessage) template.TemplateOpts {
+	return template.TemplateOpts{
+		Name:              locationMessage.Name,
+		Group:             strings.Replace(c.Opts.Group, ".", "", -1),
+		Package:           proto.GetPackage(),
+		RepoURL:           c.RepoURL,
+		RuntimeType:       locationMessage.Message.GetName(),
+		StatusType:        locationMessage.StatusType,
+		GroupGoName:       template.GroupGoName(c.Opts.Group),
+		GroupName:         c.Opts.Group,
+		ClusterScoped:     locationMessage.Scope == template.SCOPE_CLUSTER,
+		Plural:            locationMessage.Plural,
+		Singular:          locationMessage.Singular,
+		ShortNames:        locationMessage.ShortNames,
+		Defaults:          locationMessage.Defaults,
+		Owns:              locationMessage.Owns,
+		ControllerRuntime: c.Opts.ControllerRuntime(),
+	}
+}
+
+func controllerDeclared(controllers []template.TemplateOpts, name string) bool {
+	for _, controller := range controllers {
+		if controller.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *controllerGenerator) generateMakefile() error {
@@ -294,7 +590,25 @@ func (c *controllerGenerator) generateMakefile() error {
 	return nil
 }
 
+func (c *controllerGenerator) generateServer() error {
+	// The controller-runtime manager serves the metrics itself
+	if c.Opts.ControllerRuntime() {
+		return nil
+	}
+	filename := fmt.Sprintf("pkg/server/server.go")
+	server, err := gotemplate.New("Server").Parse(template.SERVER_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	var empty struct{}
+	return c.runTemplate(filename, server, empty)
+}
+
 func (c *controllerGenerator) generateSignals() error {
+	// controller-runtime provides ctrl.SetupSignalHandler
+	if c.Opts.ControllerRuntime() {
+		return nil
+	}
 	{
 
 		filename := fmt.Sprintf("pkg/signals/signal.go")
@@ -337,8 +651,11 @@ func (c *controllerGenerator) generateSignals() error {
 
 func (c *controllerGenerator) generateKubeAPI() error {
 
-	locationMessageMap := c.getLocationMessage()
-	group := c.Opts[GROUP_OPTION]
+	locationMessageMap, err := c.getLocationMessage()
+	if err != nil {
+		return err
+	}
+	group := c.Opts.Group
 	{
 		filename := fmt.Sprintf("pkg/apis/%s/register.go", strings.Replace(group, ".", "", -1))
 		registerGroup, err := gotemplate.New("K8sGroup").Funcs(template.FuncMap).Parse(template.REGISTER_GROUP_TEMPLATE)
@@ -352,9 +669,12 @@ func (c *controllerGenerator) generateKubeAPI() error {
 			return err
 		}
 	}
+	var errs GeneratorErrors
 	generatedDocPackage := make(map[string]bool)
-	for index, filename := range c.Request.FileToGenerate {
-		proto := c.Request.ProtoFile[index]
+	packages := make([]string, 0)
+	packageTypes := make(map[string]*template.ProtoFile)
+	for _, filename := range c.Request.FileToGenerate {
+		proto := c.registry.File(filename)
 		{
 			if _, ok := generatedDocPackage[proto.GetPackage()]; !ok {
 				filename := fmt.Sprintf("pkg/apis/%s/%s/doc.go", strings.Replace(group, ".", "", -1), proto.GetPackage())
@@ -367,7 +687,7 @@ func (c *controllerGenerator) generateKubeAPI() error {
 					Group:   group,
 				})
 				if err != nil {
-					return err
+					errs = append(errs, fmt.Errorf("%s: %v", proto.GetName(), err))
 				}
 				generatedDocPackage[proto.GetPackage()] = true
 			}
@@ -378,12 +698,29 @@ func (c *controllerGenerator) generateKubeAPI() error {
 		k8stypes.Package = proto.GetPackage()
 		k8stypes.Messages = make([]*template.ProtoMessage, 0)
 		k8stypes.Group = strings.Replace(group, ".", "", -1)
-		k8stypes.RepoURL = EXAMPLE_REPO
+		k8stypes.RepoURL = c.RepoURL
+		k8stypes.ControllerRuntime = c.Opts.ControllerRuntime()
 		for _, locationMessage := range locationMessages {
 			message := &template.ProtoMessage{}
-			message.Name = locationMessage.Message.GetName()
+			message.Name = locationMessage.Name
+			message.StatusType = locationMessage.StatusType
+			message.Scope = locationMessage.Scope
+			message.Plural = locationMessage.Plural
+			message.Singular = locationMessage.Singular
+			message.ShortNames = locationMessage.ShortNames
+			message.Categories = locationMessage.Categories
 			message.RuntimeType = fmt.Sprintf(INTERNAL_FORMAT, locationMessage.Message.GetName())
-			message.LeadingComments = locationMessage.Comments
+			if c.Opts.NativeTypes() {
+				message.RuntimeType = locationMessage.Name + "Spec"
+			}
+			message.LeadingComments = append([]string{}, locationMessage.Comments...)
+			// client-gen only knows about the scope through its own marker
+			if _, ok := annotationValue(message.LeadingComments, template.NON_NAMESPACED_MARKER); !ok && message.Scope == template.SCOPE_CLUSTER {
+				message.LeadingComments = append(message.LeadingComments, " "+template.NON_NAMESPACED_MARKER)
+			}
+			if locationMessage.Plural != template.ResourcePlural(locationMessage.Name) {
+				message.LeadingComments = append(message.LeadingComments, " "+template.RESOURCE_NAME_MARKER+locationMessage.Plural)
+			}
 			k8stypes.Messages = append(k8stypes.Messages, message)
 		}
 		filename := fmt.Sprintf("pkg/apis/%s/%s/%sTypes.go", strings.Replace(group, ".", "", -1), proto.GetPackage(), strings.Replace(path.Base(filename), ".proto", "", -1))
@@ -393,22 +730,60 @@ func (c *controllerGenerator) generateKubeAPI() error {
 		}
 		err = c.runTemplate(filename, types, k8stypes)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("%s: %v", proto.GetName(), err))
 		}
-		//Generate the package register
-		{
-			filename := fmt.Sprintf("pkg/apis/%s/%s/%sRegister.go", strings.Replace(group, ".", "", -1), proto.GetPackage(), strings.Replace(path.Base(proto.GetName()), ".proto", "", -1))
-			types, err := gotemplate.New("Types").Funcs(template.FuncMap).Parse(template.REGISTER_TYPES_TEMPLATE)
-			if err != nil {
-				return err
+		// Every file of a package shares the package register
+		if _, ok := packageTypes[proto.GetPackage()]; !ok {
+			packages = append(packages, proto.GetPackage())
+			packageTypes[proto.GetPackage()] = &template.ProtoFile{
+				Package: k8stypes.Package,
+				Group:   k8stypes.Group,
+				RepoURL: k8stypes.RepoURL,
 			}
-			err = c.runTemplate(filename, types, k8stypes)
-			if err != nil {
-				return err
+		}
+		packageTypes[proto.GetPackage()].Messages = append(packageTypes[proto.GetPackage()].Messages, k8stypes.Messages...)
+	}
+	if c.Opts.NativeTypes() {
+		if err := c.generateNativeTypes(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := c.generateConverters(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := c.generateValidation(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.generateDefaults(); err != nil {
+		errs = append(errs, err)
+	}
+	//Generate the package register
+	register, err := gotemplate.New("Types").Funcs(template.FuncMap).Parse(template.REGISTER_TYPES_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	conditions, err := gotemplate.New("Conditions").Funcs(template.FuncMap).Parse(template.CONDITIONS_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range packages {
+		for _, message := range packageTypes[pkg].Messages {
+			if message.StatusType == "" {
+				continue
 			}
+			filename := fmt.Sprintf("pkg/apis/%s/%s/conditions.go", strings.Replace(group, ".", "", -1), pkg)
+			if err := c.runTemplate(filename, conditions, packageTypes[pkg]); err != nil {
+				errs = append(errs, fmt.Errorf("package %s: %v", pkg, err))
+			}
+			break
+		}
+		filename := fmt.Sprintf("pkg/apis/%s/%s/register.go", strings.Replace(group, ".", "", -1), pkg)
+		err = c.runTemplate(filename, register, packageTypes[pkg])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("package %s: %v", pkg, err))
 		}
 	}
-	return nil
+	return errs.errorOrNil()
 }
 
 func (c *controllerGenerator) runTemplate(filename string, tpl *gotemplate.Template, tpldata interface{}) error {
@@ -417,97 +792,464 @@ func (c *controllerGenerator) runTemplate(filename string, tpl *gotemplate.Templ
 	writer := bufio.NewWriter(&buf)
 	err := tpl.Execute(writer, &tpldata)
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: %v", filename, err)
 	}
 	writer.Flush()
 	content := buf.Bytes()
-	//TODO: Check for .go extension
-	if formatted, err := format.Source([]byte(content)); err == nil {
-		//We can format this
+	if path.Ext(filename) == ".go" {
+		formatted, err := format.Source([]byte(content))
+		if err != nil {
+			return fmt.Errorf("%s: generated code does not compile: %v", filename, err)
+		}
 		content = formatted
 	}
+	c.writeFile(filename, content)
+	return nil
+}
+
+// runScaffold generates a file which users own once it exists. protoc would
+// overwrite it, so it is left out of the response when found below the output directory.
+func (c *controllerGenerator) runScaffold(filename string, tpl *gotemplate.Template, tpldata interface{}) error {
+	existing := filepath.Join(c.Opts.OutDir, filepath.FromSlash(path.Join(c.Opts.Prefix, filename)))
+	if _, err := os.Stat(existing); err == nil {
+		println(fmt.Sprintf("Kept: %s", existing))
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return c.runTemplate(filename, tpl, tpldata)
+}
+
+// writeFile adds a generated file to the response
+func (c *controllerGenerator) writeFile(filename string, content []byte) {
 	fileContent := string(content)
+	outputName := path.Join(c.Opts.Prefix, filename)
 	var file plugin.CodeGeneratorResponse_File
-	file.Name = &filename
+	file.Name = &outputName
 	file.Content = &fileContent
-	println(fmt.Sprintf("Generated: %s", filename))
+	println(fmt.Sprintf("Generated: %s", outputName))
 	c.Response.File = append(c.Response.File, &file)
-	return nil
 }
 
-func (c *controllerGenerator) getLocationMessage() map[string][]*LocationMessage {
+func (c *controllerGenerator) getLocationMessage() (map[string][]*LocationMessage, error) {
 
+	var errs GeneratorErrors
 	ret := make(map[string][]*LocationMessage)
-	for index, filename := range c.Request.FileToGenerate {
+	kinds := make(map[string]string)
+	for _, filename := range c.Request.FileToGenerate {
 		locationMessages := make([]*LocationMessage, 0)
-		proto := c.Request.ProtoFile[index]
-		desc := proto.GetSourceCodeInfo()
-		locations := desc.GetLocation()
-		for _, location := range locations {
+		proto := c.registry.File(filename)
+		locations := make(map[string]*descriptor.SourceCodeInfo_Location)
+		for _, location := range proto.GetSourceCodeInfo().GetLocation() {
+			locations[pathKey(location.GetPath())] = location
+		}
+		// Only top level messages may be runtime objects
+		for index, message := range proto.GetMessageType() {
+			location, ok := locations[pathKey([]int32{4, int32(index)})]
+			if !ok || !strings.Contains(location.GetLeadingComments(), "k8s.io/apimachinery/pkg/runtime.Object") {
+				continue
+			}
 			comments := strings.Split(location.GetLeadingComments(), "\n")
-			for _, comment := range comments {
-				if strings.Contains(comment, "k8s.io/apimachinery/pkg/runtime.Object") {
-					message := proto.GetMessageType()[location.GetPath()[1]]
-					locationMessages = append(locationMessages, &LocationMessage{
-						Message:  message,
-						Location: location,
-						Comments: comments[:len(comments)-1],
-					})
-				}
+			comments = comments[:len(comments)-1]
+			name, err := kindName(message, comments)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, message.GetName(), err))
+				continue
+			}
+			// Kinds share a Go package with every other generated message
+			qualifiedKind := proto.GetPackage() + "." + name
+			if other, ok := kinds[qualifiedKind]; ok {
+				errs = append(errs, fmt.Errorf("%s: message %s: kind `%s` is already used by message %s", filename, message.GetName(), name, other))
+				continue
+			}
+			kinds[qualifiedKind] = message.GetName()
+			if other := c.registry.Message(qualifiedName(proto.GetPackage(), name)); other != nil && other.Message != message {
+				errs = append(errs, fmt.Errorf("%s: message %s: kind `%s` collides with message %s", filename, message.GetName(), name, other.Message.GetName()))
+			}
+			locationMessage := &LocationMessage{
+				Message:  message,
+				Location: location,
+				Comments: comments,
+				Name:     name,
+			}
+			scope, err := resourceScope(comments)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, message.GetName(), err))
+				continue
 			}
+			locationMessage.Scope = scope
+			if err := resourceNames(locationMessage); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, message.GetName(), err))
+				continue
+			}
+			if err := ownedResources(locationMessage); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, message.GetName(), err))
+				continue
+			}
+			if err := controllerDefaults(locationMessage); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, message.GetName(), err))
+				continue
+			}
+			if err := c.resolveStatus(proto, locationMessage); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", filename, message.GetName(), err))
+				continue
+			}
+			locationMessages = append(locationMessages, locationMessage)
 		}
 		ret[filename] = locationMessages
 	}
+	return ret, errs.errorOrNil()
+}
+
+// resolveStatus looks up the message referenced by the +drekle:k8s:status annotation.
+// The status message is generated into the same Go package as the runtime object, so
+// it must be declared in the same proto package, either in this file or an import.
+func (c *controllerGenerator) resolveStatus(proto *descriptor.FileDescriptorProto, locationMessage *LocationMessage) error {
+	statusName, ok := annotationValue(locationMessage.Comments, template.DREKLE_STATUS_TYPE_KEY)
+	if !ok {
+		return nil
+	}
+	if statusName == "" {
+		return fmt.Errorf("empty %s annotation", template.DREKLE_STATUS_TYPE_KEY)
+	}
+	info := c.registry.ResolveMessage(proto.GetPackage(), statusName)
+	if info == nil {
+		return fmt.Errorf("status message `%s` not found in %s or its imports", statusName, proto.GetName())
+	}
+	if info.File.GetPackage() != proto.GetPackage() {
+		return fmt.Errorf("status message `%s` must be declared in package `%s`", statusName, proto.GetPackage())
+	}
+	if info.Message == locationMessage.Message {
+		return fmt.Errorf("status message `%s` must not be the runtime object itself", statusName)
+	}
+	// The generated ResourceStatus adds these fields next to the ones of the message
+	for _, field := range info.Message.GetField() {
+		switch field.GetName() {
+		case "conditions", "observedGeneration":
+			return fmt.Errorf("status message `%s` must not declare the field `%s`, it is added to every status", statusName, field.GetName())
+		}
+	}
+	locationMessage.StatusMessage = info.Message
+	locationMessage.StatusType = gogen.CamelCaseSlice(info.Nested)
+	locationMessage.StatusFile = info.File.GetName()
+	locationMessage.StatusTypeName = qualifiedName(info.File.GetPackage(), info.Nested...)
+	return nil
+}
+
+// resourceScope returns the scope declared with the +drekle:k8s:scope annotation
+// or the client-gen +genclient:nonNamespaced marker. Kinds are namespaced by default.
+func resourceScope(comments []string) (string, error) {
+	scope, ok := annotationValue(comments, template.DREKLE_SCOPE_KEY)
+	if !ok {
+		if _, nonNamespaced := annotationValue(comments, template.NON_NAMESPACED_MARKER); nonNamespaced {
+			return template.SCOPE_CLUSTER, nil
+		}
+		return template.SCOPE_NAMESPACED, nil
+	}
+	for _, known := range []string{template.SCOPE_NAMESPACED, template.SCOPE_CLUSTER} {
+		if strings.EqualFold(scope, known) {
+			return known, nil
+		}
+	}
+	return "", fmt.Errorf("scope `%s` must be %s or %s", scope, template.SCOPE_NAMESPACED, template.SCOPE_CLUSTER)
+}
+
+// resourceNames sets the plural, singular, short names and categories of the kind.
+// The plural defaults to the one client-gen derives from the kind.
+func resourceNames(locationMessage *LocationMessage) error {
+	locationMessage.Plural = template.ResourcePlural(locationMessage.Name)
+	locationMessage.Singular = strings.ToLower(locationMessage.Name)
+	if plural, ok := annotationValue(locationMessage.Comments, template.DREKLE_PLURAL_KEY); ok {
+		locationMessage.Plural = plural
+	}
+	if singular, ok := annotationValue(locationMessage.Comments, template.DREKLE_SINGULAR_KEY); ok {
+		locationMessage.Singular = singular
+	}
+	if shortNames, ok := annotationValue(locationMessage.Comments, template.DREKLE_SHORT_NAMES_KEY); ok {
+		locationMessage.ShortNames = splitList(shortNames)
+	}
+	if categories, ok := annotationValue(locationMessage.Comments, template.DREKLE_CATEGORIES_KEY); ok {
+		locationMessage.Categories = splitList(categories)
+	}
+
+	names := append([]string{locationMessage.Plural, locationMessage.Singular}, locationMessage.ShortNames...)
+	names = append(names, locationMessage.Categories...)
+	for _, name := range names {
+		if err := validateResourceName(name); err != nil {
+			return err
+		}
+	}
+	if locationMessage.Plural == locationMessage.Singular {
+		return fmt.Errorf("plural and singular must differ, both are `%s`", locationMessage.Plural)
+	}
+	return nil
+}
+
+// builtinGroupGoNames maps the built-in API groups to their accessor in the client-go
+// informer factory. Owned resources of other groups have no shared informers.
+var builtinGroupGoNames = map[string]string{
+	"":                             "Core",
+	"admissionregistration.k8s.io": "Admissionregistration",
+	"apps":                         "Apps",
+	"autoscaling":                  "Autoscaling",
+	"batch":                        "Batch",
+	"certificates.k8s.io":          "Certificates",
+	"coordination.k8s.io":          "Coordination",
+	"events.k8s.io":                "Events",
+	"extensions":                   "Extensions",
+	"networking.k8s.io":            "Networking",
+	"node.k8s.io":                  "Node",
+	"policy":                       "Policy",
+	"rbac.authorization.k8s.io":    "Rbac",
+	"scheduling.k8s.io":            "Scheduling",
+	"settings.k8s.io":              "Settings",
+	"storage.k8s.io":               "Storage",
+}
+
+// ownedResources parses the owned kinds of the +drekle:k8s:owns annotation. Each is
+// written as group/version.Kind, the core group may be omitted or written as core.
+func ownedResources(locationMessage *LocationMessage) error {
+	value, ok := annotationValue(locationMessage.Comments, template.DREKLE_OWNS_KEY)
+	if !ok {
+		return nil
+	}
+	var errs GeneratorErrors
+	seen := make(map[string]bool)
+	for _, element := range splitList(value) {
+		dot := strings.LastIndex(element, ".")
+		if dot < 0 {
+			errs = append(errs, fmt.Errorf("owned kind `%s` must be written as group/version.Kind", element))
+			continue
+		}
+		owned := template.OwnedResource{Version: element[:dot], Kind: element[dot+1:]}
+		if slash := strings.Index(owned.Version, "/"); slash >= 0 {
+			owned.Group, owned.Version = owned.Version[:slash], owned.Version[slash+1:]
+		}
+		if owned.Group == "core" {
+			owned.Group = ""
+		}
+		groupGoName, ok := builtinGroupGoNames[owned.Group]
+		if !ok {
+			errs = append(errs, fmt.Errorf("owned kind `%s` must belong to a built-in API group", element))
+			continue
+		}
+		if owned.Version == "" || owned.Kind == "" || strings.ToUpper(owned.Kind[:1]) != owned.Kind[:1] {
+			errs = append(errs, fmt.Errorf("owned kind `%s` must be written as group/version.Kind", element))
+			continue
+		}
+		owned.GroupGoName = groupGoName
+		owned.VersionGoName = strings.ToUpper(owned.Version[:1]) + owned.Version[1:]
+		owned.Resource = template.ResourcePlural(owned.Kind)
+		// One informer per resource, whatever the version
+		if seen[owned.Group+"/"+owned.Resource] {
+			errs = append(errs, fmt.Errorf("owned kind `%s` is listed twice", element))
+			continue
+		}
+		seen[owned.Group+"/"+owned.Resource] = true
+		locationMessage.Owns = append(locationMessage.Owns, owned)
+	}
+	return errs.errorOrNil()
+}
+
+// controllerDefaults sets the controller defaults of the kind from its annotations
+func controllerDefaults(locationMessage *LocationMessage) error {
+	defaults := template.DEFAULT_CONTROLLER_DEFAULTS
+	var errs GeneratorErrors
+	for _, annotation := range []struct {
+		key   string
+		parse func(value string) error
+	}{
+		{template.DREKLE_WORKERS_KEY, func(value string) (err error) {
+			defaults.Workers, err = strconv.Atoi(value)
+			if err == nil && defaults.Workers < 1 {
+				err = fmt.Errorf("at least one worker is required")
+			}
+			return err
+		}},
+		{template.DREKLE_RESYNC_PERIOD_KEY, func(value string) (err error) {
+			defaults.ResyncPeriod, err = time.ParseDuration(value)
+			if err == nil && defaults.ResyncPeriod < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+			return err
+		}},
+		{template.DREKLE_RATE_LIMIT_BASE_DELAY_KEY, func(value string) (err error) {
+			defaults.RateLimitBaseDelay, err = time.ParseDuration(value)
+			if err == nil && defaults.RateLimitBaseDelay <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+			return err
+		}},
+		{template.DREKLE_RATE_LIMIT_MAX_DELAY_KEY, func(value string) (err error) {
+			defaults.RateLimitMaxDelay, err = time.ParseDuration(value)
+			if err == nil && defaults.RateLimitMaxDelay <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+			return err
+		}},
+		{template.DREKLE_RATE_LIMIT_QPS_KEY, func(value string) (err error) {
+			defaults.RateLimitQPS, err = strconv.ParseFloat(value, 64)
+			if err == nil && !(defaults.RateLimitQPS > 0) {
+				err = fmt.Errorf("must be positive")
+			}
+			return err
+		}},
+		{template.DREKLE_RATE_LIMIT_BURST_KEY, func(value string) (err error) {
+			defaults.RateLimitBurst, err = strconv.Atoi(value)
+			if err == nil && defaults.RateLimitBurst < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+			return err
+		}},
+	} {
+		value, ok := annotationValue(locationMessage.Comments, annotation.key)
+		if !ok {
+			continue
+		}
+		if err := annotation.parse(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s annotation `%s`: %v", annotation.key, value, err))
+		}
+	}
+	if defaults.RateLimitBaseDelay > defaults.RateLimitMaxDelay {
+		errs = append(errs, fmt.Errorf("rate limit base delay %s exceeds the max delay %s", defaults.RateLimitBaseDelay, defaults.RateLimitMaxDelay))
+	}
+	locationMessage.Defaults = defaults
+	return errs.errorOrNil()
+}
+
+// validateResourceName checks the name is a lower case DNS-1035 label, as required
+// for CRD names.
+func validateResourceName(name string) error {
+	if name == "" || len(name) > 63 {
+		return fmt.Errorf("resource name `%s` must be 1 to 63 characters", name)
+	}
+	for i, r := range name {
+		switch {
+		case 'a' <= r && r <= 'z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '-'):
+		default:
+			return fmt.Errorf("resource name `%s` must consist of lower case letters, digits and '-', starting with a letter", name)
+		}
+	}
+	if strings.HasSuffix(name, "-") {
+		return fmt.Errorf("resource name `%s` must not end with '-'", name)
+	}
+	return nil
+}
+
+// splitList splits a comma separated annotation value
+func splitList(value string) []string {
+	ret := make([]string, 0)
+	for _, element := range strings.Split(value, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			ret = append(ret, element)
+		}
+	}
 	return ret
 }
 
+// annotationValue returns the value of the first `+key=value` annotation found in
+// the comments.
+func annotationValue(comments []string, key string) (string, bool) {
+	for _, comment := range comments {
+		comment = strings.TrimSpace(comment)
+		if strings.HasPrefix(comment, key) {
+			return strings.TrimSpace(strings.TrimPrefix(comment, key)), true
+		}
+	}
+	return "", false
+}
+
+// kindName returns the Kubernetes kind of a runtime object message, which is the
+// message name unless overridden with the +drekle:k8s:name annotation.
+func kindName(message *descriptor.DescriptorProto, comments []string) (string, error) {
+	name, ok := annotationValue(comments, template.DREKLE_NAME_ANNOTATION_KEY)
+	if !ok {
+		return message.GetName(), nil
+	}
+	if name == "" {
+		return "", fmt.Errorf("empty %s annotation", template.DREKLE_NAME_ANNOTATION_KEY)
+	}
+	for i, r := range name {
+		switch {
+		case i == 0 && 'A' <= r && r <= 'Z':
+		case i > 0 && ('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'):
+		default:
+			return "", fmt.Errorf("kind `%s` must be an upper camel case identifier", name)
+		}
+	}
+	return name, nil
+}
+
 func (c *controllerGenerator) generateCobra() error {
 	// There was a choice here to enforce that each runtime object was its own controller
 
-	locationMessages := c.getLocationMessage()
-
-	var cobraRootOpts template.CobraRootOpts
-	cobraRootOpts.ControllerNames = make([]string, 0)
-	for index, filename := range c.Request.FileToGenerate {
-		proto := c.Request.ProtoFile[index]
-		locationMessage := locationMessages[filename]
-		for _, location := range locationMessage {
-			for _, comment := range location.Comments {
-				if strings.Contains(comment, "k8s.io/apimachinery/pkg/runtime.Object") {
-					cobraRootOpts.ControllerNames = append(cobraRootOpts.ControllerNames, location.Message.GetName())
-				}
-			}
+	locationMessages, err := c.getLocationMessage()
+	if err != nil {
+		return err
+	}
+
+	controller, err := gotemplate.New("Test").Funcs(template.FuncMap).Parse(template.CobraControllerTemplate)
+	if err != nil {
+		return err
+	}
+
+	var errs GeneratorErrors
+	cobraRootOpts := template.CobraRootOpts{
+		Name:        template.GroupGoName(c.Opts.Group),
+		RepoURL:     c.RepoURL,
+		Group:       strings.Replace(c.Opts.Group, ".", "", -1),
+		Controllers: make([]template.TemplateOpts, 0),
+	}
+	packages := make(map[string]bool)
+	for _, filename := range c.Request.FileToGenerate {
+		proto := c.registry.File(filename)
+		if !packages[proto.GetPackage()] {
+			packages[proto.GetPackage()] = true
+			cobraRootOpts.Packages = append(cobraRootOpts.Packages, proto.GetPackage())
 		}
-		{
-			// Generate the root command
-			filename := fmt.Sprintf("cmd/root.go")
-			cobraroot, err := gotemplate.New("CobraRoot").Funcs(template.FuncMap).Parse(template.CobraRootTemplate)
-			if err != nil {
-				return err
+		for _, location := range locationMessages[filename] {
+			tpl := c.templateOpts(proto, location)
+			if controllerDeclared(cobraRootOpts.Controllers, tpl.Name) {
+				continue
 			}
-			err = c.runTemplate(filename, cobraroot, &cobraRootOpts)
-			if err != nil {
-				return err
+			cobraRootOpts.Controllers = append(cobraRootOpts.Controllers, tpl)
+			cobraRootOpts.Namespaced = cobraRootOpts.Namespaced || !tpl.ClusterScoped
+			// controller-runtime projects have a single main.go
+			if c.Opts.ControllerRuntime() {
+				continue
 			}
-		}
-		for _, name := range cobraRootOpts.ControllerNames {
-			// Generate each controller command
-			var tpl template.TemplateOpts
-			tpl.Name = name
-			tpl.Package = proto.GetPackage()
-			tpl.RepoURL = EXAMPLE_REPO
 
-			filename := fmt.Sprintf("cmd/%s.go", name)
-			controller, err := gotemplate.New("Test").Funcs(template.FuncMap).Parse(template.CobraControllerTemplate)
-			if err != nil {
-				return err
-			}
+			// Generate each controller command
+			filename := fmt.Sprintf("cmd/%s.go", tpl.Name)
 			err = c.runTemplate(filename, controller, &tpl)
 			if err != nil {
-				return err
+				errs = append(errs, fmt.Errorf("%s: message %s: %v", proto.GetName(), tpl.Name, err))
 			}
 		}
 	}
+	if c.Opts.ControllerRuntime() {
+		main, err := gotemplate.New("CR-Main").Funcs(template.FuncMap).Parse(template.CR_MAIN_TEMPLATE)
+		if err != nil {
+			return err
+		}
+		if err := c.runTemplate("cmd/main.go", main, &cobraRootOpts); err != nil {
+			errs = append(errs, err)
+		}
+		return errs.errorOrNil()
+	}
+	{
+		// Generate the root command
+		filename := fmt.Sprintf("cmd/root.go")
+		cobraroot, err := gotemplate.New("CobraRoot").Funcs(template.FuncMap).Parse(template.CobraRootTemplate)
+		if err != nil {
+			return err
+		}
+		err = c.runTemplate(filename, cobraroot, &cobraRootOpts)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
 
-	return nil
+	return errs.errorOrNil()
 }
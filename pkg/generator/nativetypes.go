@@ -0,0 +1,403 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	gotemplate "text/template"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	gogen "github.com/golang/protobuf/protoc-gen-go/generator"
+
+	"github.com/drekle/protoc-gen-k8s/pkg/template"
+)
+
+// nativeTypes names and renders the plain Go types generated with types=native.
+// Each local proto package becomes a package below pkg/apis holding a struct per
+// message and a string type per enum.
+type nativeTypes struct {
+	registry *registry
+	// apisPath is the import path of the directory holding the API packages
+	apisPath string
+	// kinds maps the fully qualified runtime object messages to their kind
+	kinds map[string]string
+	// isLocal reports whether native types are generated for a file
+	isLocal func(file *descriptor.FileDescriptorProto) bool
+	// defaulted holds the proto3 fields with a default annotation
+	defaulted map[*descriptor.FieldDescriptorProto]bool
+}
+
+// nativeTypes indexes the runtime objects of the request, whose structs are named
+// after their kind
+func (c *controllerGenerator) nativeTypes() (*nativeTypes, error) {
+	locationMessageMap, err := c.getLocationMessage()
+	if err != nil {
+		return nil, err
+	}
+	kinds := make(map[string]string)
+	for _, filename := range c.Request.FileToGenerate {
+		proto := c.registry.File(filename)
+		for _, locationMessage := range locationMessageMap[filename] {
+			kinds[qualifiedName(proto.GetPackage(), locationMessage.Message.GetName())] = locationMessage.Name
+		}
+	}
+	defaults, err := c.fieldDefaults()
+	if err != nil {
+		return nil, err
+	}
+	defaulted := make(map[*descriptor.FieldDescriptorProto]bool)
+	for name, fields := range defaults {
+		info := c.registry.Message(name)
+		for _, field := range info.Message.GetField() {
+			if fields[field.GetName()] != nil && info.File.GetSyntax() == "proto3" {
+				defaulted[field] = true
+			}
+		}
+	}
+	return &nativeTypes{
+		registry:  c.registry,
+		apisPath:  path.Join(c.RepoURL, "pkg", "apis", strings.Replace(c.Opts.Group, ".", "", -1)),
+		kinds:     kinds,
+		isLocal:   c.isLocalFile,
+		defaulted: defaulted,
+	}, nil
+}
+
+// generateNativeTypes writes the Go types of every local proto file into the API
+// package of the file
+func (c *controllerGenerator) generateNativeTypes() error {
+	native, err := c.nativeTypes()
+	if err != nil {
+		return err
+	}
+	types, err := gotemplate.New("NativeTypes").Funcs(template.FuncMap).Parse(template.NATIVE_TYPES_TEMPLATE)
+	if err != nil {
+		return err
+	}
+
+	// The kinds, their lists and statuses share the packages with the native types
+	declared := make(map[string]string)
+	for name, kind := range native.kinds {
+		pkg := strings.Split(strings.TrimPrefix(name, "."), ".")[0]
+		for _, goName := range []string{kind, kind + "List", kind + "ResourceStatus"} {
+			declared[pkg+"."+goName] = "kind " + kind
+		}
+	}
+
+	var errs GeneratorErrors
+	convert, err := gotemplate.New("WellKnown").Funcs(template.FuncMap).Parse(template.WELL_KNOWN_CONVERT_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	if err := c.runTemplate("pkg/convert/wellknown.go", convert, wellKnownOpts()); err != nil {
+		errs = append(errs, err)
+	}
+	for _, filename := range c.registry.Dependencies(c.Request.FileToGenerate) {
+		file := c.registry.File(filename)
+		if !c.isLocalFile(file) {
+			continue
+		}
+		nativeFile, err := native.file(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		names := make([]string, 0)
+		for _, enum := range nativeFile.Enums {
+			names = append(names, enum.Name)
+		}
+		for _, s := range nativeFile.Structs {
+			names = append(names, s.Name)
+		}
+		for _, name := range names {
+			if other, ok := declared[file.GetPackage()+"."+name]; ok {
+				errs = append(errs, fmt.Errorf("%s: Go type `%s` is already declared by %s", filename, name, other))
+				continue
+			}
+			declared[file.GetPackage()+"."+name] = filename
+		}
+		out := fmt.Sprintf("pkg/apis/%s/%s/%s.go", strings.Replace(c.Opts.Group, ".", "", -1), file.GetPackage(), strings.TrimSuffix(path.Base(filename), ".proto"))
+		if err := c.runTemplate(out, types, nativeFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", filename, err))
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// messageName is the Go name of a message. The struct of a runtime object is the
+// spec of its kind, the kind itself is declared by K8S_TYPE_TEMPLATE.
+func (n *nativeTypes) messageName(info *messageInfo) string {
+	if kind, ok := n.kinds[qualifiedName(info.File.GetPackage(), info.Nested...)]; ok {
+		return kind + "Spec"
+	}
+	return gogen.CamelCaseSlice(info.Nested)
+}
+
+func (n *nativeTypes) enumName(info *enumInfo) string {
+	return gogen.CamelCaseSlice(info.Nested)
+}
+
+// enumValueName is the Go constant of an enum value. Values are scoped to the
+// package like in proto, so they are prefixed with the enum name.
+func (n *nativeTypes) enumValueName(info *enumInfo, value *descriptor.EnumValueDescriptorProto) string {
+	return n.enumName(info) + "_" + value.GetName()
+}
+
+// file renders the types declared in the proto file
+func (n *nativeTypes) file(file *descriptor.FileDescriptorProto) (*template.NativeFile, error) {
+	native := &template.NativeFile{
+		Package: file.GetPackage(),
+		Source:  file.GetName(),
+	}
+	imports := make(map[string]string)
+	for _, enum := range n.enums(file) {
+		native.Enums = append(native.Enums, n.enum(enum))
+	}
+	var errs GeneratorErrors
+	for _, message := range n.messages(file) {
+		s, err := n.message(message, imports)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		native.Structs = append(native.Structs, s)
+	}
+	for alias, importPath := range imports {
+		native.Imports = append(native.Imports, template.NativeImport{Alias: alias, Path: importPath})
+	}
+	sort.Slice(native.Imports, func(i, j int) bool {
+		return native.Imports[i].Path < native.Imports[j].Path
+	})
+	return native, errs.errorOrNil()
+}
+
+// messages lists the messages of the file, see registry.FileMessages
+func (n *nativeTypes) messages(file *descriptor.FileDescriptorProto) []*messageInfo {
+	return n.registry.FileMessages(file)
+}
+
+// enums lists the enums of the file, top level enums first
+func (n *nativeTypes) enums(file *descriptor.FileDescriptorProto) []*enumInfo {
+	ret := make([]*enumInfo, 0)
+	for _, enum := range file.GetEnumType() {
+		ret = append(ret, n.registry.Enum(qualifiedName(file.GetPackage(), enum.GetName())))
+	}
+	for _, message := range n.messages(file) {
+		for _, enum := range message.Message.GetEnumType() {
+			nested := append(append([]string{}, message.Nested...), enum.GetName())
+			ret = append(ret, n.registry.Enum(qualifiedName(file.GetPackage(), nested...)))
+		}
+	}
+	return ret
+}
+
+func (n *nativeTypes) enum(info *enumInfo) template.NativeEnum {
+	enum := template.NativeEnum{
+		Name:     n.enumName(info),
+		Comments: commentLines(description(info.Comments)),
+	}
+	for _, value := range info.Enum.GetValue() {
+		enum.Values = append(enum.Values, template.NativeEnumValue{
+			Name:  n.enumValueName(info, value),
+			Value: value.GetName(),
+		})
+	}
+	return enum
+}
+
+func (n *nativeTypes) message(info *messageInfo, imports map[string]string) (template.NativeStruct, error) {
+	name := qualifiedName(info.File.GetPackage(), info.Nested...)
+	s := template.NativeStruct{
+		Name:     n.messageName(info),
+		Comments: commentLines(description(info.Comments)),
+	}
+	var errs GeneratorErrors
+	for _, field := range info.Message.GetField() {
+		goType, omitEmpty, err := n.fieldType(info.File, field, imports)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: message %s: field %s: %v", info.File.GetName(), strings.TrimPrefix(name, "."), field.GetName(), err))
+			continue
+		}
+		tag := nativeJSONName(field)
+		if omitEmpty {
+			tag += ",omitempty"
+		}
+		comment := description(info.FieldComments[field.GetName()])
+		if oneof := oneofComment(info.Message, field); oneof != "" {
+			comment = strings.TrimSpace(comment + "\n" + oneof)
+		}
+		s.Fields = append(s.Fields, template.NativeField{
+			Name:     gogen.CamelCase(field.GetName()),
+			Type:     goType,
+			Tag:      fmt.Sprintf(`json:"%s"`, tag),
+			Comments: commentLines(comment),
+		})
+	}
+	return s, errs.errorOrNil()
+}
+
+// fieldType returns the Go type of the field and whether it is left out of the JSON
+// when empty. Fields without presence in proto3 are omitted when zero, messages,
+// oneof members, proto2 optional scalars and proto3 optional or defaulted ones are
+// pointers.
+func (n *nativeTypes) fieldType(file *descriptor.FileDescriptorProto, field *descriptor.FieldDescriptorProto, imports map[string]string) (string, bool, error) {
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		if entry := n.registry.Message(field.GetTypeName()); entry != nil && entry.Message.GetOptions().GetMapEntry() {
+			key, err := n.valueType(file, entry.Message.GetField()[0], imports)
+			if err != nil {
+				return "", false, err
+			}
+			value, err := n.valueType(file, entry.Message.GetField()[1], imports)
+			if err != nil {
+				return "", false, err
+			}
+			return fmt.Sprintf("map[%s]%s", key, value), true, nil
+		}
+		value, err := n.valueType(file, field, imports)
+		if err != nil {
+			return "", false, err
+		}
+		return "[]" + value, true, nil
+	}
+	value, err := n.valueType(file, field, imports)
+	if err != nil {
+		return "", false, err
+	}
+	if field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES {
+		// A nil slice already tells an unset field apart
+		return value, field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REQUIRED, nil
+	}
+	if wellKnown, ok := wellKnownTypes[field.GetTypeName()]; ok && field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		if wellKnown.Pointer {
+			return "*" + value, true, nil
+		}
+		return value, true, nil
+	}
+	switch {
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+		field.OneofIndex != nil,
+		file.GetSyntax() != "proto3" && field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_OPTIONAL,
+		n.presence(field):
+		return "*" + value, true, nil
+	case field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED:
+		return value, false, nil
+	}
+	return value, true, nil
+}
+
+// presence reports whether the native field of a proto3 scalar or enum is a pointer,
+// which the golang/protobuf message holds without presence. proto3 optional fields
+// and fields with a default are, so that a zero value is not taken for unset.
+func (n *nativeTypes) presence(field *descriptor.FieldDescriptorProto) bool {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return false
+	}
+	return n.registry.Optional(field) || n.defaulted[field]
+}
+
+// valueType returns the Go type of a single value of the field, ignoring its label
+func (n *nativeTypes) valueType(file *descriptor.FileDescriptorProto, field *descriptor.FieldDescriptorProto, imports map[string]string) (string, error) {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "float64", nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "float32", nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "int32", nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "int64", nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "uint32", nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "uint64", nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "bool", nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "string", nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte", nil
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		info := n.registry.Enum(field.GetTypeName())
+		if info == nil {
+			return "", fmt.Errorf("enum `%s` not found", field.GetTypeName())
+		}
+		return n.qualify(file, info.File, n.enumName(info), imports)
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if wellKnown, ok := wellKnownTypes[field.GetTypeName()]; ok {
+			if wellKnown.Import != nil {
+				imports[wellKnown.Import.Alias] = wellKnown.Import.Path
+			}
+			return wellKnown.GoType, nil
+		}
+		info := n.registry.Message(field.GetTypeName())
+		if info == nil {
+			return "", fmt.Errorf("message `%s` not found", field.GetTypeName())
+		}
+		return n.qualify(file, info.File, n.messageName(info), imports)
+	}
+	return "", fmt.Errorf("unsupported field type %s", field.GetType())
+}
+
+// qualify refers to a type declared in another proto file from file
+func (n *nativeTypes) qualify(file *descriptor.FileDescriptorProto, declared *descriptor.FileDescriptorProto, name string, imports map[string]string) (string, error) {
+	if declared.GetPackage() == file.GetPackage() {
+		return name, nil
+	}
+	if !n.isLocal(declared) {
+		return "", fmt.Errorf("`%s` from %s has no native Go type", name, declared.GetName())
+	}
+	imports[declared.GetPackage()] = path.Join(n.apisPath, declared.GetPackage())
+	return declared.GetPackage() + "." + name, nil
+}
+
+// oneofComment documents the oneof a field is a member of, if any. Native types
+// declare each member as an optional field of the message.
+func oneofComment(message *descriptor.DescriptorProto, field *descriptor.FieldDescriptorProto) string {
+	if field.OneofIndex == nil {
+		return ""
+	}
+	members := make([]string, 0)
+	for _, other := range message.GetField() {
+		if other.OneofIndex != nil && other.GetOneofIndex() == field.GetOneofIndex() {
+			members = append(members, nativeJSONName(other))
+		}
+	}
+	return fmt.Sprintf("At most one of %s may be set.", strings.Join(members, ", "))
+}
+
+// nativeJSONName is the lowerCamel JSON name of a native field. protoc keeps the case
+// of the first letter in json_name, so the leading capitals are lower cased the way
+// Kubernetes names fields: `Replicas` becomes `replicas` and `URLPath` `urlPath`.
+func nativeJSONName(field *descriptor.FieldDescriptorProto) string {
+	name := []rune(field.GetJsonName())
+	upper := 0
+	for upper < len(name) && unicode.IsUpper(name[upper]) {
+		upper++
+	}
+	// The last of several capitals followed by a lower case letter starts a word
+	if upper > 1 && upper < len(name) && unicode.IsLower(name[upper]) {
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		name[i] = unicode.ToLower(name[i])
+	}
+	return string(name)
+}
+
+// commentLines splits a comment into the lines of a Go comment
+func commentLines(comment string) []string {
+	if comment == "" {
+		return nil
+	}
+	return strings.Split(comment, "\n")
+}
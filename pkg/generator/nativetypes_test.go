@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// testNativeTypes names the native types of the registry, which declares no kind
+func testNativeTypes(r *registry) *nativeTypes {
+	return &nativeTypes{
+		registry:  r,
+		apisPath:  "github.com/example/controller/pkg/apis/drekleexampleio",
+		kinds:     make(map[string]string),
+		isLocal:   func(*descriptor.FileDescriptorProto) bool { return true },
+		defaulted: make(map[*descriptor.FieldDescriptorProto]bool),
+	}
+}
+
+func TestNativeJSONName(t *testing.T) {
+	tests := []struct {
+		jsonName string
+		want     string
+	}{
+		{"replicas", "replicas"},
+		{"maxSurge", "maxSurge"},
+		{"Replicas", "replicas"},
+		{"MaxSurge", "maxSurge"},
+		{"URLPath", "urlPath"},
+		{"ID", "id"},
+		{"ByID", "byID"},
+		{"X", "x"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		field := &descriptor.FieldDescriptorProto{JsonName: proto.String(test.jsonName)}
+		if got := nativeJSONName(field); got != test.want {
+			t.Errorf("nativeJSONName(%q) = %q, want %q", test.jsonName, got, test.want)
+		}
+	}
+}
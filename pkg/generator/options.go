@@ -0,0 +1,327 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	GROUP_OPTION     = "group"
+	MODULE_OPTION    = "module"
+	PREFIX_OPTION    = "prefix"
+	OUT_DIR_OPTION   = "out_dir"
+	FRAMEWORK_OPTION = "framework"
+	TYPES_OPTION     = "types"
+	SKIP_OPTION      = "skip"
+	HELP_OPTION      = "help"
+)
+
+// Generation steps which may be disabled with the `skip` option
+const (
+	STEP_CONTROLLER = "controller"
+	STEP_COBRA      = "cobra"
+	STEP_SIGNALS    = "signals"
+	STEP_SERVER     = "server"
+	STEP_KUBEAPI    = "kubeapi"
+	STEP_CRD        = "crd"
+	STEP_RBAC       = "rbac"
+	STEP_GOGEN      = "gogen"
+	STEP_GOMOD      = "gomod"
+	STEP_HACK       = "hack"
+	STEP_MAKEFILE   = "makefile"
+)
+
+// Controller frameworks the generated project may be built on
+const (
+	FRAMEWORK_CLIENT_GO          = "client-go"
+	FRAMEWORK_CONTROLLER_RUNTIME = "controller-runtime"
+)
+
+// Go types the spec and status of a kind may be generated as
+const (
+	TYPES_PROTOBUF = "protobuf"
+	TYPES_NATIVE   = "native"
+)
+
+var knownSteps = []string{
+	STEP_CONTROLLER,
+	STEP_COBRA,
+	STEP_SIGNALS,
+	STEP_SERVER,
+	STEP_KUBEAPI,
+	STEP_CRD,
+	STEP_RBAC,
+	STEP_GOGEN,
+	STEP_GOMOD,
+	STEP_HACK,
+	STEP_MAKEFILE,
+}
+
+// Options are the typed plugin parameters passed with --k8s_opt
+type Options struct {
+	// Group is the Kubernetes API group of the generated types, e.g. drekle.example.io
+	Group string
+	// Module is the Go module path of the generated project
+	Module string
+	// Prefix is a directory, relative to the protoc output directory, that every
+	// generated file is written under
+	Prefix string
+	// OutDir is the --k8s_out directory relative to where protoc runs. Scaffold
+	// files which already exist below it are not generated again.
+	OutDir string
+	// Framework is either FRAMEWORK_CLIENT_GO or FRAMEWORK_CONTROLLER_RUNTIME
+	Framework string
+	// Types is either TYPES_PROTOBUF or TYPES_NATIVE
+	Types string
+	// Skip lists the generation steps which should not emit any files
+	Skip []string
+	// Help requests the option table instead of generating code
+	Help bool
+}
+
+// ControllerRuntime reports whether controllers are generated for controller-runtime
+func (o *Options) ControllerRuntime() bool {
+	return o.Framework == FRAMEWORK_CONTROLLER_RUNTIME
+}
+
+// NativeTypes reports whether the API packages hold plain Go structs instead of the
+// golang/protobuf messages, which are then generated below pkg/proto
+func (o *Options) NativeTypes() bool {
+	return o.Types == TYPES_NATIVE
+}
+
+// Skipped reports whether the named generation step has been disabled
+func (o *Options) Skipped(step string) bool {
+	for _, skipped := range o.Skip {
+		if skipped == step {
+			return true
+		}
+	}
+	return false
+}
+
+type optionSpec struct {
+	Name        string
+	Description string
+	Default     string
+	Required    bool
+	Repeated    bool
+	// set applies a single value of the option
+	set func(opts *Options, value string) error
+}
+
+var optionSpecs = []optionSpec{
+	{
+		Name:        GROUP_OPTION,
+		Description: "Kubernetes API group of the generated types, e.g. drekle.example.io",
+		Required:    true,
+		set: func(opts *Options, value string) error {
+			if err := validateGroup(value); err != nil {
+				return err
+			}
+			opts.Group = value
+			return nil
+		},
+	},
+	{
+		Name:        MODULE_OPTION,
+		Description: "Go module path of the generated project; falls back to go_package, then " + EXAMPLE_REPO,
+		set: func(opts *Options, value string) error {
+			if err := validateImportPath(value); err != nil {
+				return err
+			}
+			opts.Module = value
+			return nil
+		},
+	},
+	{
+		Name:        PREFIX_OPTION,
+		Description: "Directory relative to --k8s_out that all files are generated under",
+		set: func(opts *Options, value string) error {
+			cleaned := path.Clean(value)
+			if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+				return fmt.Errorf("`%s` must be relative to the output directory", value)
+			}
+			opts.Prefix = cleaned
+			return nil
+		},
+	},
+	{
+		Name:        OUT_DIR_OPTION,
+		Description: "The --k8s_out directory; scaffold files already below it are kept instead of regenerated",
+		Default:     ".",
+		set: func(opts *Options, value string) error {
+			if value == "" {
+				return fmt.Errorf("out_dir must not be empty")
+			}
+			opts.OutDir = value
+			return nil
+		},
+	},
+	{
+		Name:        FRAMEWORK_OPTION,
+		Description: fmt.Sprintf("Controller framework, %s workqueues or %s managers", FRAMEWORK_CLIENT_GO, FRAMEWORK_CONTROLLER_RUNTIME),
+		Default:     FRAMEWORK_CLIENT_GO,
+		set: func(opts *Options, value string) error {
+			switch value {
+			case FRAMEWORK_CLIENT_GO, FRAMEWORK_CONTROLLER_RUNTIME:
+				opts.Framework = value
+				return nil
+			}
+			return fmt.Errorf("unknown framework `%s`, one of %s|%s", value, FRAMEWORK_CLIENT_GO, FRAMEWORK_CONTROLLER_RUNTIME)
+		},
+	},
+	{
+		Name:        TYPES_OPTION,
+		Description: fmt.Sprintf("Go types of the spec and status, %s messages or %s structs with lowerCamel json names", TYPES_PROTOBUF, TYPES_NATIVE),
+		Default:     TYPES_PROTOBUF,
+		set: func(opts *Options, value string) error {
+			switch value {
+			case TYPES_PROTOBUF, TYPES_NATIVE:
+				opts.Types = value
+				return nil
+			}
+			return fmt.Errorf("unknown types `%s`, one of %s|%s", value, TYPES_PROTOBUF, TYPES_NATIVE)
+		},
+	},
+	{
+		Name:        SKIP_OPTION,
+		Description: fmt.Sprintf("Generation step to skip, one of %s", strings.Join(knownSteps, "|")),
+		Repeated:    true,
+		set: func(opts *Options, value string) error {
+			for _, step := range knownSteps {
+				if step == value {
+					opts.Skip = append(opts.Skip, value)
+					return nil
+				}
+			}
+			return fmt.Errorf("unknown step `%s`", value)
+		},
+	},
+	{
+		Name:        HELP_OPTION,
+		Description: "Print this table instead of generating code",
+		set: func(opts *Options, value string) error {
+			opts.Help = value == "" || value == "true"
+			return nil
+		},
+	},
+}
+
+// validateGroup checks that the group is a lowercase DNS subdomain with at least one
+// dot, as CustomResourceDefinitions require, and that dropping its dots leaves a valid
+// Go package name for pkg/apis/<group>
+func validateGroup(group string) error {
+	if group == "" {
+		return fmt.Errorf("group must not be empty")
+	}
+	if len(group) > 253 {
+		return fmt.Errorf("group `%s` is longer than 253 characters", group)
+	}
+	labels := strings.Split(group, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("group `%s` must be a domain with at least one dot, e.g. %s.example.io", group, group)
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("group `%s` must consist of dot separated labels of 1 to 63 characters", group)
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+				return fmt.Errorf("group `%s` may only contain lowercase letters, digits and dots, as the Go package %s is derived from it", group, strings.Replace(group, ".", "", -1))
+			}
+		}
+	}
+	if group[0] >= '0' && group[0] <= '9' {
+		return fmt.Errorf("group `%s` must start with a letter, as the Go package %s is derived from it", group, strings.Replace(group, ".", "", -1))
+	}
+	return nil
+}
+
+// ParseOptions parses the comma separated key=value parameter protoc passes to the
+// plugin. Values are split on the first `=` only, so they may themselves contain `=`.
+func ParseOptions(parameter string) (*Options, error) {
+	opts := &Options{}
+	var errs GeneratorErrors
+
+	seen := make(map[string]bool)
+	for _, element := range strings.Split(parameter, ",") {
+		element = strings.TrimSpace(element)
+		if element == "" {
+			continue
+		}
+		kv := strings.SplitN(element, "=", 2)
+		key := kv[0]
+		value := ""
+		if len(kv) > 1 {
+			value = kv[1]
+		}
+		spec := lookupOptionSpec(key)
+		if spec == nil {
+			errs = append(errs, fmt.Errorf("Unknown Option `%s`", key))
+			continue
+		}
+		if seen[key] && !spec.Repeated {
+			errs = append(errs, fmt.Errorf("Option `%s` may only be set once", key))
+			continue
+		}
+		seen[key] = true
+		if err := spec.set(opts, value); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid option `%s`: %v", key, err))
+		}
+	}
+	if opts.Help {
+		return nil, fmt.Errorf("%s", OptionsHelp())
+	}
+
+	for _, spec := range optionSpecs {
+		if seen[spec.Name] {
+			continue
+		}
+		if spec.Required {
+			errs = append(errs, fmt.Errorf("Missing required option `%s`, e.g. --k8s_opt=%s=<value>", spec.Name, spec.Name))
+		} else if spec.Default != "" {
+			if err := spec.set(opts, spec.Default); err != nil {
+				errs = append(errs, fmt.Errorf("Invalid default for option `%s`: %v", spec.Name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return opts, nil
+}
+
+func lookupOptionSpec(name string) *optionSpec {
+	for i := range optionSpecs {
+		if optionSpecs[i].Name == name {
+			return &optionSpecs[i]
+		}
+	}
+	return nil
+}
+
+// OptionsHelp renders the table of supported options
+func OptionsHelp() string {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "protoc-gen-k8s options, passed as --k8s_opt=key=value,key=value:")
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  OPTION\tDEFAULT\tDESCRIPTION")
+	for _, spec := range optionSpecs {
+		def := spec.Default
+		switch {
+		case spec.Required:
+			def = "(required)"
+		case spec.Repeated:
+			def = "(repeated)"
+		case def == "":
+			def = "-"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", spec.Name, def, spec.Description)
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
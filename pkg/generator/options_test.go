@@ -0,0 +1,225 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		parameter string
+		want      *Options
+		// err is a part of the expected error, empty when parsing succeeds
+		err string
+	}{
+		{
+			name:      "defaults",
+			parameter: "group=drekle.example.io",
+			want:      &Options{Group: "drekle.example.io", OutDir: ".", Framework: FRAMEWORK_CLIENT_GO, Types: TYPES_PROTOBUF},
+		},
+		{
+			name:      "every option",
+			parameter: "group=drekle.example.io,module=github.com/example/controller,prefix=gen,out_dir=out,framework=controller-runtime,types=native",
+			want: &Options{
+				Group:     "drekle.example.io",
+				Module:    "github.com/example/controller",
+				Prefix:    "gen",
+				OutDir:    "out",
+				Framework: FRAMEWORK_CONTROLLER_RUNTIME,
+				Types:     TYPES_NATIVE,
+			},
+		},
+		{
+			name:      "split on the first =",
+			parameter: "group=drekle.example.io,out_dir=a=b",
+			want:      &Options{Group: "drekle.example.io", OutDir: "a=b", Framework: FRAMEWORK_CLIENT_GO, Types: TYPES_PROTOBUF},
+		},
+		{
+			name:      "blanks and empty elements",
+			parameter: " group=drekle.example.io , ,types=native,",
+			want:      &Options{Group: "drekle.example.io", OutDir: ".", Framework: FRAMEWORK_CLIENT_GO, Types: TYPES_NATIVE},
+		},
+		{
+			name:      "repeated skip",
+			parameter: "group=drekle.example.io,skip=crd,skip=rbac",
+			want:      &Options{Group: "drekle.example.io", OutDir: ".", Framework: FRAMEWORK_CLIENT_GO, Types: TYPES_PROTOBUF, Skip: []string{STEP_CRD, STEP_RBAC}},
+		},
+		{
+			name:      "unknown step",
+			parameter: "group=drekle.example.io,skip=docs",
+			err:       "Invalid option `skip`: unknown step `docs`",
+		},
+		{
+			name:      "unknown key",
+			parameter: "group=drekle.example.io,repo=github.com/example/controller",
+			err:       "Unknown Option `repo`",
+		},
+		{
+			name:      "unknown key without value",
+			parameter: "group=drekle.example.io,verbose",
+			err:       "Unknown Option `verbose`",
+		},
+		{
+			name:      "set twice",
+			parameter: "group=drekle.example.io,group=other.example.io",
+			err:       "Option `group` may only be set once",
+		},
+		{
+			name:      "missing group",
+			parameter: "types=native",
+			err:       "Missing required option `group`",
+		},
+		{
+			name:      "invalid group",
+			parameter: "group=my-app.example.io",
+			err:       "Invalid option `group`: group `my-app.example.io` may only contain",
+		},
+		{
+			name:      "invalid module",
+			parameter: "group=drekle.example.io,module=github.com//controller",
+			err:       "Invalid option `module`: import path `github.com//controller` has an empty path element",
+		},
+		{
+			name:      "prefix outside the output directory",
+			parameter: "group=drekle.example.io,prefix=../gen",
+			err:       "Invalid option `prefix`: `../gen` must be relative to the output directory",
+		},
+		{
+			name:      "unknown framework",
+			parameter: "group=drekle.example.io,framework=operator-sdk",
+			err:       "Invalid option `framework`: unknown framework `operator-sdk`",
+		},
+		{
+			name:      "help",
+			parameter: "help",
+			err:       GROUP_OPTION,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := ParseOptions(test.parameter)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ParseOptions(%q) error = %v, want %q", test.parameter, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOptions(%q) error = %v", test.parameter, err)
+			}
+			if !reflect.DeepEqual(opts, test.want) {
+				t.Errorf("ParseOptions(%q) = %+v, want %+v", test.parameter, opts, test.want)
+			}
+		})
+	}
+}
+
+func TestParseOptionsReportsEveryError(t *testing.T) {
+	_, err := ParseOptions("repo=x,types=go")
+	errs, ok := err.(GeneratorErrors)
+	if !ok {
+		t.Fatalf("ParseOptions error = %#v, want GeneratorErrors", err)
+	}
+	// The unknown option, the invalid types and the missing group
+	if len(errs) != 3 {
+		t.Errorf("ParseOptions reported %d errors, want 3: %v", len(errs), err)
+	}
+}
+
+func TestValidateGroup(t *testing.T) {
+	tests := []struct {
+		group string
+		valid bool
+	}{
+		{"drekle.example.io", true},
+		{"apps2.example.io", true},
+		{"a.b", true},
+		{"", false},
+		{"example", false},
+		{"my-app.example.io", false},
+		{"Drekle.example.io", false},
+		{"drekle..io", false},
+		{".example.io", false},
+		{"example.io.", false},
+		{"1password.example.io", false},
+		{"drekle_app.example.io", false},
+		{strings.Repeat("a", 64) + ".io", false},
+		{strings.Repeat("a.", 127) + "io", false},
+	}
+	for _, test := range tests {
+		if err := validateGroup(test.group); (err == nil) != test.valid {
+			t.Errorf("validateGroup(%q) = %v, want valid %v", test.group, err, test.valid)
+		}
+	}
+}
+
+func TestValidateImportPath(t *testing.T) {
+	tests := []struct {
+		importPath string
+		valid      bool
+	}{
+		{"github.com/example/controller", true},
+		{"example.com/a-b/c_d/e~f/v2", true},
+		{"controller", true},
+		{"", false},
+		{"/github.com/example", false},
+		{"github.com/example/", false},
+		{"github.com//example", false},
+		{"github.com/.example", false},
+		{"github.com/example.", false},
+		{"github.com/exa mple", false},
+		{"github.com/exa:mple", false},
+		{"github.com/exämple", false},
+	}
+	for _, test := range tests {
+		if err := validateImportPath(test.importPath); (err == nil) != test.valid {
+			t.Errorf("validateImportPath(%q) = %v, want valid %v", test.importPath, err, test.valid)
+		}
+	}
+}
+
+func TestOptionsHelp(t *testing.T) {
+	defaults := make(map[string]string)
+	for _, line := range strings.Split(OptionsHelp(), "\n")[2:] {
+		fields := strings.Fields(line)
+		defaults[fields[0]] = fields[1]
+	}
+	for _, spec := range optionSpecs {
+		if _, ok := defaults[spec.Name]; !ok {
+			t.Errorf("the help lacks the option %s", spec.Name)
+		}
+	}
+	tests := []struct {
+		option string
+		want   string
+	}{
+		{"group", "(required)"},
+		{"module", "-"},
+		{"out_dir", "."},
+		{"framework", FRAMEWORK_CLIENT_GO},
+		{"types", TYPES_PROTOBUF},
+		{"skip", "(repeated)"},
+	}
+	for _, test := range tests {
+		if defaults[test.option] != test.want {
+			t.Errorf("the help gives %s the default %q, want %q", test.option, defaults[test.option], test.want)
+		}
+	}
+}
+
+func TestOptionsSkipped(t *testing.T) {
+	opts, err := ParseOptions("group=drekle.example.io,skip=crd,skip=rbac")
+	if err != nil {
+		t.Fatalf("ParseOptions error = %v", err)
+	}
+	for _, step := range []string{STEP_CRD, STEP_RBAC} {
+		if !opts.Skipped(step) {
+			t.Errorf("Skipped(%s) = false, want true", step)
+		}
+	}
+	if opts.Skipped(STEP_CONTROLLER) {
+		t.Errorf("Skipped(%s) = true, want false", STEP_CONTROLLER)
+	}
+}
@@ -0,0 +1,291 @@
+package generator
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// registry indexes the descriptors of a CodeGeneratorRequest by file name and by
+// fully qualified message and enum name. protoc orders ProtoFile topologically
+// with dependencies first, so descriptors must never be looked up by the index of
+// a file in FileToGenerate.
+type registry struct {
+	files    map[string]*descriptor.FileDescriptorProto
+	messages map[string]*messageInfo
+	enums    map[string]*enumInfo
+	// optional holds the proto3 optional fields, see foldSyntheticOneofs
+	optional map[*descriptor.FieldDescriptorProto]bool
+}
+
+type messageInfo struct {
+	Message *descriptor.DescriptorProto
+	File    *descriptor.FileDescriptorProto
+	// Nested is the path of message names from the top level message, e.g. [Outer, Inner]
+	Nested []string
+	// Comments are the leading comments of the message
+	Comments string
+	// FieldComments are the leading comments of each field keyed by field name
+	FieldComments map[string]string
+}
+
+type enumInfo struct {
+	Enum *descriptor.EnumDescriptorProto
+	File *descriptor.FileDescriptorProto
+	// Nested is the path of names from the top level declaration, e.g. [Outer, Color]
+	Nested   []string
+	Comments string
+}
+
+func newRegistry(files []*descriptor.FileDescriptorProto) *registry {
+	r := &registry{
+		files:    make(map[string]*descriptor.FileDescriptorProto),
+		messages: make(map[string]*messageInfo),
+		enums:    make(map[string]*enumInfo),
+		optional: make(map[*descriptor.FieldDescriptorProto]bool),
+	}
+	for _, file := range files {
+		r.files[file.GetName()] = file
+		locations := make(map[string]*descriptor.SourceCodeInfo_Location)
+		for _, location := range file.GetSourceCodeInfo().GetLocation() {
+			locations[pathKey(location.GetPath())] = location
+		}
+		for i, enum := range file.GetEnumType() {
+			r.addEnum(file, nil, enum, []int32{5, int32(i)}, locations)
+		}
+		for i, message := range file.GetMessageType() {
+			r.addMessage(file, nil, message, []int32{4, int32(i)}, locations)
+		}
+	}
+	return r
+}
+
+func (r *registry) addMessage(file *descriptor.FileDescriptorProto, parent []string, message *descriptor.DescriptorProto, path []int32, locations map[string]*descriptor.SourceCodeInfo_Location) {
+	nested := append(append([]string{}, parent...), message.GetName())
+	name := qualifiedName(file.GetPackage(), nested...)
+	info := &messageInfo{
+		Message:       message,
+		File:          file,
+		Nested:        nested,
+		Comments:      locations[pathKey(path)].GetLeadingComments(),
+		FieldComments: make(map[string]string),
+	}
+	r.messages[name] = info
+	r.foldSyntheticOneofs(message)
+	for i, field := range message.GetField() {
+		info.FieldComments[field.GetName()] = locations[pathKey(childPath(path, 2, int32(i)))].GetLeadingComments()
+	}
+	for i, enum := range message.GetEnumType() {
+		r.addEnum(file, nested, enum, childPath(path, 4, int32(i)), locations)
+	}
+	for i, child := range message.GetNestedType() {
+		r.addMessage(file, nested, child, childPath(path, 3, int32(i)), locations)
+	}
+}
+
+func (r *registry) addEnum(file *descriptor.FileDescriptorProto, parent []string, enum *descriptor.EnumDescriptorProto, path []int32, locations map[string]*descriptor.SourceCodeInfo_Location) {
+	nested := append(append([]string{}, parent...), enum.GetName())
+	r.enums[qualifiedName(file.GetPackage(), nested...)] = &enumInfo{
+		Enum:     enum,
+		File:     file,
+		Nested:   nested,
+		Comments: locations[pathKey(path)].GetLeadingComments(),
+	}
+}
+
+// proto3OptionalField is the proto3_optional field of FieldDescriptorProto, which
+// golang/protobuf v1.3 predates and keeps unrecognized
+const proto3OptionalField = 17
+
+// foldSyntheticOneofs turns the proto3 optional fields of the message into plain
+// fields. protoc declares each in a oneof of its own, which protoc-gen-go v1.3 would
+// generate as a oneof, so the flag and the synthetic oneofs are removed from the
+// indexed descriptors, which must be a copy of the request. The messages of pkg/proto
+// hold the fields without presence, native types hold a pointer.
+func (r *registry) foldSyntheticOneofs(message *descriptor.DescriptorProto) {
+	synthetic := len(message.GetOneofDecl())
+	for _, field := range message.GetField() {
+		optional, unrecognized := stripVarintField(field.XXX_unrecognized, proto3OptionalField)
+		if !optional {
+			continue
+		}
+		field.XXX_unrecognized = unrecognized
+		r.optional[field] = true
+		// Synthetic oneofs are declared after every other oneof
+		if field.OneofIndex != nil && int(field.GetOneofIndex()) < synthetic {
+			synthetic = int(field.GetOneofIndex())
+		}
+		field.OneofIndex = nil
+	}
+	message.OneofDecl = message.GetOneofDecl()[:synthetic]
+}
+
+// cloneFiles deep copies the descriptors of a request for a registry to index
+func cloneFiles(files []*descriptor.FileDescriptorProto) []*descriptor.FileDescriptorProto {
+	ret := make([]*descriptor.FileDescriptorProto, 0, len(files))
+	for _, file := range files {
+		ret = append(ret, proto.Clone(file).(*descriptor.FileDescriptorProto))
+	}
+	return ret
+}
+
+// stripVarintField reports whether the encoded fields set the varint field to a non
+// zero value and returns them without it
+func stripVarintField(data []byte, number uint64) (bool, []byte) {
+	set := false
+	kept := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		key, n := proto.DecodeVarint(data)
+		if n == 0 {
+			break
+		}
+		size := n
+		switch key & 7 {
+		case proto.WireVarint:
+			value, m := proto.DecodeVarint(data[n:])
+			if m == 0 {
+				return set, append(kept, data...)
+			}
+			size += m
+			if key>>3 == number {
+				set = value != 0
+				data = data[size:]
+				continue
+			}
+		case proto.WireFixed64:
+			size += 8
+		case proto.WireBytes:
+			length, m := proto.DecodeVarint(data[n:])
+			if m == 0 {
+				return set, append(kept, data...)
+			}
+			size += m + int(length)
+		case proto.WireFixed32:
+			size += 4
+		default:
+			// Groups are not used by descriptors
+			return set, append(kept, data...)
+		}
+		if size > len(data) {
+			break
+		}
+		kept = append(kept, data[:size]...)
+		data = data[size:]
+	}
+	return set, append(kept, data...)
+}
+
+// Optional reports whether the field is declared proto3 optional
+func (r *registry) Optional(field *descriptor.FieldDescriptorProto) bool {
+	return r.optional[field]
+}
+
+// File returns the descriptor of the named proto file
+func (r *registry) File(name string) *descriptor.FileDescriptorProto {
+	return r.files[name]
+}
+
+// Message returns the message with the fully qualified name, e.g. `.v1.Person`
+func (r *registry) Message(name string) *messageInfo {
+	return r.messages[name]
+}
+
+// FileMessages lists the messages of the file in declaration order, nested messages
+// after their parent. Map entries are left out.
+func (r *registry) FileMessages(file *descriptor.FileDescriptorProto) []*messageInfo {
+	ret := make([]*messageInfo, 0)
+	var visit func(parent []string, messages []*descriptor.DescriptorProto)
+	visit = func(parent []string, messages []*descriptor.DescriptorProto) {
+		for _, message := range messages {
+			if message.GetOptions().GetMapEntry() {
+				continue
+			}
+			nested := append(append([]string{}, parent...), message.GetName())
+			ret = append(ret, r.Message(qualifiedName(file.GetPackage(), nested...)))
+			visit(nested, message.GetNestedType())
+		}
+	}
+	visit(nil, file.GetMessageType())
+	return ret
+}
+
+// Enum returns the enum with the fully qualified name, e.g. `.v1.Color`
+func (r *registry) Enum(name string) *enumInfo {
+	return r.enums[name]
+}
+
+// ResolveMessage resolves a message name the way protoc resolves type references
+// declared in the package: names starting with a dot are fully qualified, others
+// are searched from the innermost package scope outwards.
+func (r *registry) ResolveMessage(pkg string, name string) *messageInfo {
+	if strings.HasPrefix(name, ".") {
+		return r.messages[name]
+	}
+	scope := pkg
+	for {
+		candidate := "." + name
+		if scope != "" {
+			candidate = "." + scope + "." + name
+		}
+		if info, ok := r.messages[candidate]; ok {
+			return info
+		}
+		if scope == "" {
+			return nil
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// Dependencies returns the transitive imports of the files, dependencies first
+func (r *registry) Dependencies(filenames []string) []string {
+	ret := make([]string, 0)
+	seen := make(map[string]bool)
+	var visit func(filename string)
+	visit = func(filename string) {
+		if seen[filename] {
+			return
+		}
+		seen[filename] = true
+		for _, dependency := range r.File(filename).GetDependency() {
+			visit(dependency)
+		}
+		ret = append(ret, filename)
+	}
+	for _, filename := range filenames {
+		visit(filename)
+	}
+	return ret
+}
+
+// qualifiedName returns the fully qualified name of a top level or nested message
+func qualifiedName(pkg string, nested ...string) string {
+	if pkg == "" {
+		return "." + strings.Join(nested, ".")
+	}
+	if len(nested) == 0 {
+		return "." + pkg
+	}
+	return "." + pkg + "." + strings.Join(nested, ".")
+}
+
+// childPath copies the parent path so sibling paths never share a backing array
+func childPath(path []int32, elements ...int32) []int32 {
+	child := make([]int32, 0, len(path)+len(elements))
+	child = append(child, path...)
+	return append(child, elements...)
+}
+
+func pathKey(path []int32) string {
+	elements := make([]string, len(path))
+	for i, element := range path {
+		elements[i] = strconv.Itoa(int(element))
+	}
+	return strings.Join(elements, ",")
+}
@@ -0,0 +1,266 @@
+package generator
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func TestFoldSyntheticOneofs(t *testing.T) {
+	// proto3_optional = true, followed by an unknown length delimited field 18
+	unknown := append(proto.EncodeVarint(18<<3|proto.WireBytes), 2, 'a', 'b')
+	optional := func(field *descriptor.FieldDescriptorProto, oneof int32) *descriptor.FieldDescriptorProto {
+		field.OneofIndex = proto.Int32(oneof)
+		field.XXX_unrecognized = append(append(proto.EncodeVarint(proto3OptionalField<<3), 1), unknown...)
+		return field
+	}
+	member := testField("a", 2, descriptor.FieldDescriptorProto_TYPE_STRING, "")
+	member.OneofIndex = proto.Int32(0)
+	message := &descriptor.DescriptorProto{
+		Name: proto.String("Spec"),
+		Field: []*descriptor.FieldDescriptorProto{
+			optional(testField("replicas", 1, descriptor.FieldDescriptorProto_TYPE_INT32, ""), 1),
+			member,
+			optional(testField("label", 3, descriptor.FieldDescriptorProto_TYPE_STRING, ""), 2),
+			testField("plain", 4, descriptor.FieldDescriptorProto_TYPE_INT32, ""),
+		},
+		OneofDecl: []*descriptor.OneofDescriptorProto{
+			{Name: proto.String("choice")},
+			{Name: proto.String("_replicas")},
+			{Name: proto.String("_label")},
+		},
+	}
+	r := newRegistry([]*descriptor.FileDescriptorProto{{
+		Name:        proto.String("v1.proto"),
+		Package:     proto.String("v1"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptor.DescriptorProto{message},
+	}})
+
+	if len(message.GetOneofDecl()) != 1 || message.GetOneofDecl()[0].GetName() != "choice" {
+		t.Errorf("oneofs = %v, want only choice", message.GetOneofDecl())
+	}
+	for _, field := range message.GetField() {
+		wantOptional := field.GetName() == "replicas" || field.GetName() == "label"
+		if r.Optional(field) != wantOptional {
+			t.Errorf("Optional(%s) = %v, want %v", field.GetName(), r.Optional(field), wantOptional)
+		}
+		if wantOptional && field.OneofIndex != nil {
+			t.Errorf("%s is still in oneof %d", field.GetName(), field.GetOneofIndex())
+		}
+		if wantOptional && !bytes.Equal(field.XXX_unrecognized, unknown) {
+			t.Errorf("%s keeps the unrecognized fields %v, want %v", field.GetName(), field.XXX_unrecognized, unknown)
+		}
+	}
+	if member.GetOneofIndex() != 0 {
+		t.Errorf("a is in oneof %d, want 0", member.GetOneofIndex())
+	}
+}
+
+func TestStripVarintField(t *testing.T) {
+	key := func(number uint64, wireType uint64) []byte {
+		return proto.EncodeVarint(number<<3 | wireType)
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	fixed32 := join(key(18, proto.WireFixed32), []byte{1, 2, 3, 4})
+	fixed64 := join(key(19, proto.WireFixed64), []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	tests := []struct {
+		name string
+		data []byte
+		set  bool
+		kept []byte
+	}{
+		{"empty", nil, false, []byte{}},
+		{"set", join(key(17, proto.WireVarint), []byte{1}), true, []byte{}},
+		{"false", join(key(17, proto.WireVarint), []byte{0}), false, []byte{}},
+		{"other varint", join(key(16, proto.WireVarint), []byte{1}), false, join(key(16, proto.WireVarint), []byte{1})},
+		{"fixed fields kept", join(fixed32, key(17, proto.WireVarint), []byte{1}, fixed64), true, join(fixed32, fixed64)},
+		{"truncated kept", join(key(18, proto.WireBytes), []byte{5, 'a'}), false, join(key(18, proto.WireBytes), []byte{5, 'a'})},
+	}
+	for _, test := range tests {
+		set, kept := stripVarintField(test.data, proto3OptionalField)
+		if set != test.set || !bytes.Equal(kept, test.kept) {
+			t.Errorf("%s: stripVarintField = %v, %v, want %v, %v", test.name, set, kept, test.set, test.kept)
+		}
+	}
+}
+
+func TestNewControllerGeneratorKeepsTheRequest(t *testing.T) {
+	field := testField("replicas", 1, descriptor.FieldDescriptorProto_TYPE_INT32, "")
+	field.OneofIndex = proto.Int32(0)
+	field.XXX_unrecognized = append(proto.EncodeVarint(proto3OptionalField<<3), 1)
+	request := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"v1.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{{
+			Name:    proto.String("v1.proto"),
+			Package: proto.String("v1"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptor.DescriptorProto{{
+				Name:      proto.String("Spec"),
+				Field:     []*descriptor.FieldDescriptorProto{field},
+				OneofDecl: []*descriptor.OneofDescriptorProto{{Name: proto.String("_replicas")}},
+			}},
+		}},
+	}
+	want := proto.Clone(request)
+	c, err := NewControllerGenerator(request, &plugin.CodeGeneratorResponse{}, &Options{Group: "drekle.example.io", Module: "github.com/example/controller"})
+	if err != nil {
+		t.Fatalf("NewControllerGenerator error = %v", err)
+	}
+	if !proto.Equal(request, want) {
+		t.Errorf("NewControllerGenerator changed the request to %v, want %v", request, want)
+	}
+	if !c.registry.Optional(testMessageField(c.registry, ".v1.Spec", "replicas")) {
+		t.Errorf("the registry does not hold replicas as proto3 optional")
+	}
+}
+
+// lookupRegistry indexes nested declarations and comments across the packages a,
+// a.b and the default package, which imports the others
+func lookupRegistry() *registry {
+	location := func(comment string, path ...int32) *descriptor.SourceCodeInfo_Location {
+		return &descriptor.SourceCodeInfo_Location{Path: path, LeadingComments: proto.String(comment)}
+	}
+	a := &descriptor.FileDescriptorProto{
+		Name:    proto.String("a.proto"),
+		Package: proto.String("a"),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name:  proto.String("Outer"),
+			Field: []*descriptor.FieldDescriptorProto{testField("inner", 1, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".a.Outer.Inner")},
+			NestedType: []*descriptor.DescriptorProto{
+				{Name: proto.String("Inner"), Field: []*descriptor.FieldDescriptorProto{testField("kind", 1, descriptor.FieldDescriptorProto_TYPE_ENUM, ".a.Outer.Kind")}},
+				{Name: proto.String("ValuesEntry"), Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)}},
+			},
+			EnumType: []*descriptor.EnumDescriptorProto{{Name: proto.String("Kind")}},
+		}},
+		SourceCodeInfo: &descriptor.SourceCodeInfo{Location: []*descriptor.SourceCodeInfo_Location{
+			location(" outer", 4, 0),
+			location(" inner field", 4, 0, 2, 0),
+			location(" inner", 4, 0, 3, 0),
+			location(" kind field", 4, 0, 3, 0, 2, 0),
+			location(" kind", 4, 0, 4, 0),
+		}},
+	}
+	ab := &descriptor.FileDescriptorProto{
+		Name:        proto.String("ab.proto"),
+		Package:     proto.String("a.b"),
+		Dependency:  []string{"a.proto"},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Outer")}},
+	}
+	top := &descriptor.FileDescriptorProto{
+		Name:        proto.String("top.proto"),
+		Dependency:  []string{"ab.proto", "a.proto"},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Top")}},
+		EnumType:    []*descriptor.EnumDescriptorProto{{Name: proto.String("Level")}},
+	}
+	return newRegistry([]*descriptor.FileDescriptorProto{a, ab, top})
+}
+
+func TestResolveMessage(t *testing.T) {
+	r := lookupRegistry()
+	tests := []struct {
+		pkg  string
+		name string
+		want string
+	}{
+		{"a.b", ".a.Outer", ".a.Outer"},
+		{"a.b", "Outer", ".a.b.Outer"},
+		{"a", "Outer", ".a.Outer"},
+		{"a", "Outer.Inner", ".a.Outer.Inner"},
+		{"a.b", "b.Outer", ".a.b.Outer"},
+		{"a.b", "Top", ".Top"},
+		{"", "Top", ".Top"},
+		{"", "a.Outer", ".a.Outer"},
+		{"a.b", "Inner", ""},
+		{"a.b", ".Outer", ""},
+		{"", "Outer", ""},
+	}
+	for _, test := range tests {
+		got := ""
+		if info := r.ResolveMessage(test.pkg, test.name); info != nil {
+			got = qualifiedName(info.File.GetPackage(), info.Nested...)
+		}
+		if got != test.want {
+			t.Errorf("ResolveMessage(%q, %q) = %q, want %q", test.pkg, test.name, got, test.want)
+		}
+	}
+}
+
+func TestLookups(t *testing.T) {
+	r := lookupRegistry()
+	inner := r.Message(".a.Outer.Inner")
+	if inner == nil || inner.File.GetName() != "a.proto" || !reflect.DeepEqual(inner.Nested, []string{"Outer", "Inner"}) {
+		t.Fatalf("Message(.a.Outer.Inner) = %+v", inner)
+	}
+	if inner.Comments != " inner" || inner.FieldComments["kind"] != " kind field" {
+		t.Errorf("Inner has the comments %q and %q, want %q and %q", inner.Comments, inner.FieldComments["kind"], " inner", " kind field")
+	}
+	if outer := r.Message(".a.Outer"); outer.Comments != " outer" || outer.FieldComments["inner"] != " inner field" {
+		t.Errorf("Outer has the comments %q and %q, want %q and %q", outer.Comments, outer.FieldComments["inner"], " outer", " inner field")
+	}
+	if kind := r.Enum(".a.Outer.Kind"); kind == nil || kind.Comments != " kind" || !reflect.DeepEqual(kind.Nested, []string{"Outer", "Kind"}) {
+		t.Errorf("Enum(.a.Outer.Kind) = %+v", kind)
+	}
+	if level := r.Enum(".Level"); level == nil || level.File.GetName() != "top.proto" {
+		t.Errorf("Enum(.Level) = %+v", level)
+	}
+	if r.Message(".a.Outer.Kind") != nil || r.Enum(".a.Outer") != nil {
+		t.Errorf("messages and enums are looked up together")
+	}
+	if r.File("ab.proto").GetPackage() != "a.b" || r.File("b.proto") != nil {
+		t.Errorf("File looks up the wrong descriptors")
+	}
+}
+
+func TestFileMessages(t *testing.T) {
+	r := lookupRegistry()
+	got := make([]string, 0)
+	for _, info := range r.FileMessages(r.File("a.proto")) {
+		got = append(got, qualifiedName(info.File.GetPackage(), info.Nested...))
+	}
+	// Nested messages follow their parent, map entries are left out
+	if want := []string{".a.Outer", ".a.Outer.Inner"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FileMessages(a.proto) = %v, want %v", got, want)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	r := lookupRegistry()
+	tests := []struct {
+		filenames []string
+		want      []string
+	}{
+		{[]string{"a.proto"}, []string{"a.proto"}},
+		{[]string{"top.proto"}, []string{"a.proto", "ab.proto", "top.proto"}},
+		{[]string{"ab.proto", "top.proto", "a.proto"}, []string{"a.proto", "ab.proto", "top.proto"}},
+	}
+	for _, test := range tests {
+		if got := r.Dependencies(test.filenames); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Dependencies(%v) = %v, want %v", test.filenames, got, test.want)
+		}
+	}
+}
+
+func TestQualifiedName(t *testing.T) {
+	tests := []struct {
+		pkg    string
+		nested []string
+		want   string
+	}{
+		{"v1", []string{"Spec"}, ".v1.Spec"},
+		{"a.b", []string{"Outer", "Inner"}, ".a.b.Outer.Inner"},
+		{"", []string{"Top"}, ".Top"},
+		{"v1", nil, ".v1"},
+	}
+	for _, test := range tests {
+		if got := qualifiedName(test.pkg, test.nested...); got != test.want {
+			t.Errorf("qualifiedName(%q, %v) = %q, want %q", test.pkg, test.nested, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,618 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gotemplate "text/template"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	gogen "github.com/golang/protobuf/protoc-gen-go/generator"
+
+	"github.com/drekle/protoc-gen-k8s/pkg/template"
+)
+
+// fieldValidation holds the validation annotations of a field
+type fieldValidation struct {
+	Minimum, Maximum     *float64
+	Pattern              string
+	MinLength, MaxLength *int64
+	MinItems, MaxItems   *int64
+	// Enum are the allowed values as Go literals, EnumValues as schema values
+	Enum       []string
+	EnumValues []interface{}
+	Required   bool
+	// Unsigned fields are never below a minimum of zero
+	Unsigned bool
+}
+
+// fieldValidations maps fully qualified message names to the validations of their
+// fields, keyed by field name
+type fieldValidations map[string]map[string]*fieldValidation
+
+// get returns the validation of the field, nil if it has no annotations
+func (v fieldValidations) get(message string, field string) *fieldValidation {
+	return v[message][field]
+}
+
+// fieldValidations parses the validation annotations of the fields of every local
+// message
+func (c *controllerGenerator) fieldValidations() (fieldValidations, error) {
+	ret := make(fieldValidations)
+	var errs GeneratorErrors
+	for _, filename := range c.registry.Dependencies(c.Request.FileToGenerate) {
+		file := c.registry.File(filename)
+		if !c.isLocalFile(file) {
+			continue
+		}
+		for _, info := range c.registry.FileMessages(file) {
+			name := qualifiedName(file.GetPackage(), info.Nested...)
+			for _, field := range info.Message.GetField() {
+				validation, err := c.registry.parseFieldValidation(field, strings.Split(info.FieldComments[field.GetName()], "\n"))
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: message %s: field %s: %v", filename, strings.TrimPrefix(name, "."), field.GetName(), err))
+					continue
+				}
+				if validation == nil {
+					continue
+				}
+				if ret[name] == nil {
+					ret[name] = make(map[string]*fieldValidation)
+				}
+				ret[name][field.GetName()] = validation
+			}
+		}
+	}
+	return ret, errs.errorOrNil()
+}
+
+// valueField returns the field holding the values of a map, the field itself otherwise
+func (r *registry) valueField(field *descriptor.FieldDescriptorProto) (*descriptor.FieldDescriptorProto, bool) {
+	if field.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED || field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return field, false
+	}
+	if entry := r.Message(field.GetTypeName()); entry != nil && entry.Message.GetOptions().GetMapEntry() {
+		return entry.Message.GetField()[1], true
+	}
+	return field, false
+}
+
+// scalarKind classifies the values of a field for validation: integer, unsigned,
+// number or string. Other fields only support the required and item annotations.
+func scalarKind(field *descriptor.FieldDescriptorProto) string {
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_INT32,
+		descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_INT64,
+		descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "integer"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32,
+		descriptor.FieldDescriptorProto_TYPE_FIXED32,
+		descriptor.FieldDescriptorProto_TYPE_UINT64,
+		descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "unsigned"
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "number"
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return "string"
+	}
+	return ""
+}
+
+// parseFieldValidation parses the validation annotations in the comments of the
+// field, returning nil when there are none
+func (r *registry) parseFieldValidation(field *descriptor.FieldDescriptorProto, comments []string) (*fieldValidation, error) {
+	valueField, isMap := r.valueField(field)
+	kind := scalarKind(valueField)
+	numeric := kind == "integer" || kind == "unsigned" || kind == "number"
+	repeated := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED && !isMap
+
+	parseNumber := func(value string) (float64, error) {
+		if !numeric {
+			return 0, fmt.Errorf("only numeric fields have a minimum or maximum")
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, err
+		}
+		if kind != "number" && number != math.Trunc(number) {
+			return 0, fmt.Errorf("%s is not an integer", value)
+		}
+		if kind == "unsigned" && number < 0 {
+			return 0, fmt.Errorf("%s is negative but the field is unsigned", value)
+		}
+		return number, nil
+	}
+	parseLength := func(value string) (*int64, error) {
+		if kind != "string" {
+			return nil, fmt.Errorf("only string fields have a length")
+		}
+		return parseCount(value)
+	}
+	parseItems := func(value string) (*int64, error) {
+		if !repeated {
+			return nil, fmt.Errorf("only repeated fields have items")
+		}
+		return parseCount(value)
+	}
+
+	ret := &fieldValidation{Unsigned: kind == "unsigned"}
+	found := false
+	var errs GeneratorErrors
+	for _, annotation := range []struct {
+		key   string
+		parse func(value string) error
+	}{
+		{template.DREKLE_MINIMUM_KEY, func(value string) error {
+			number, err := parseNumber(value)
+			ret.Minimum = &number
+			return err
+		}},
+		{template.DREKLE_MAXIMUM_KEY, func(value string) error {
+			number, err := parseNumber(value)
+			ret.Maximum = &number
+			return err
+		}},
+		{template.DREKLE_PATTERN_KEY, func(value string) error {
+			if kind != "string" {
+				return fmt.Errorf("only string fields have a pattern")
+			}
+			ret.Pattern = value
+			_, err := regexp.Compile(value)
+			return err
+		}},
+		{template.DREKLE_MIN_LENGTH_KEY, func(value string) (err error) {
+			ret.MinLength, err = parseLength(value)
+			return err
+		}},
+		{template.DREKLE_MAX_LENGTH_KEY, func(value string) (err error) {
+			ret.MaxLength, err = parseLength(value)
+			return err
+		}},
+		{template.DREKLE_MIN_ITEMS_KEY, func(value string) (err error) {
+			ret.MinItems, err = parseItems(value)
+			return err
+		}},
+		{template.DREKLE_MAX_ITEMS_KEY, func(value string) (err error) {
+			ret.MaxItems, err = parseItems(value)
+			return err
+		}},
+		{template.DREKLE_ENUM_KEY, func(value string) error {
+			if kind == "" {
+				return fmt.Errorf("only string and numeric fields have an enum")
+			}
+			values := splitList(value)
+			if len(values) == 0 {
+				return fmt.Errorf("at least one value is required")
+			}
+			for _, element := range values {
+				if kind == "string" {
+					ret.Enum = append(ret.Enum, strconv.Quote(element))
+					ret.EnumValues = append(ret.EnumValues, element)
+					continue
+				}
+				number, err := parseNumber(element)
+				if err != nil {
+					return err
+				}
+				ret.Enum = append(ret.Enum, element)
+				if kind == "number" {
+					ret.EnumValues = append(ret.EnumValues, number)
+				} else {
+					ret.EnumValues = append(ret.EnumValues, int64(number))
+				}
+			}
+			return nil
+		}},
+		{template.DREKLE_REQUIRED_MARKER, func(value string) error {
+			if value != "" {
+				return fmt.Errorf("takes no value")
+			}
+			if field.OneofIndex != nil {
+				return fmt.Errorf("oneof members cannot be required")
+			}
+			ret.Required = true
+			return nil
+		}},
+	} {
+		value, ok := annotationValue(comments, annotation.key)
+		if !ok {
+			continue
+		}
+		found = true
+		if err := annotation.parse(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s annotation `%s`: %v", strings.TrimSuffix(annotation.key, "="), value, err))
+		}
+	}
+	if len(errs) > 0 || !found {
+		return nil, errs.errorOrNil()
+	}
+	if ret.Minimum != nil && ret.Maximum != nil && *ret.Minimum > *ret.Maximum {
+		errs = append(errs, fmt.Errorf("minimum %v exceeds the maximum %v", *ret.Minimum, *ret.Maximum))
+	}
+	if ret.MinLength != nil && ret.MaxLength != nil && *ret.MinLength > *ret.MaxLength {
+		errs = append(errs, fmt.Errorf("minimum length %d exceeds the maximum length %d", *ret.MinLength, *ret.MaxLength))
+	}
+	if ret.MinItems != nil && ret.MaxItems != nil && *ret.MinItems > *ret.MaxItems {
+		errs = append(errs, fmt.Errorf("minimum items %d exceed the maximum items %d", *ret.MinItems, *ret.MaxItems))
+	}
+	return ret, errs.errorOrNil()
+}
+
+// parseCount parses a length or number of items
+func parseCount(value string) (*int64, error) {
+	count, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("must not be negative")
+	}
+	return &count, nil
+}
+
+// apply adds the constraints to the schema of the field. Value constraints of
+// repeated fields and maps apply to their items.
+func (v *fieldValidation) apply(schema *JSONSchemaProps) {
+	values := schema
+	switch {
+	case schema.Items != nil:
+		values = schema.Items
+		schema.MinItems, schema.MaxItems = v.MinItems, v.MaxItems
+	case schema.AdditionalProperties != nil:
+		values = schema.AdditionalProperties
+	}
+	if v.Minimum != nil {
+		values.Minimum = v.Minimum
+	}
+	values.Maximum = v.Maximum
+	values.Pattern = v.Pattern
+	values.MinLength, values.MaxLength = v.MinLength, v.MaxLength
+	if v.EnumValues != nil {
+		values.Enum = v.EnumValues
+	}
+}
+
+// goTypes names the Go types of the messages, which are either the native types
+// or the golang/protobuf messages
+type goTypes struct {
+	*nativeTypes
+	// native names the types generated with types=native rather than the
+	// golang/protobuf messages
+	native bool
+}
+
+// validator renders the Validate functions of the messages of a file
+type validator struct {
+	goTypes
+	validations fieldValidations
+	// needs holds the messages with validated fields, directly or in the messages
+	// they hold
+	needs    map[string]bool
+	file     *descriptor.FileDescriptorProto
+	imports  map[string]string
+	patterns []template.ValidationPattern
+}
+
+// generateValidation writes the Validate functions next to the types of every
+// local proto file and a Validate method for each kind
+func (c *controllerGenerator) generateValidation() error {
+	native, err := c.nativeTypes()
+	if err != nil {
+		return err
+	}
+	validations, err := c.fieldValidations()
+	if err != nil {
+		return err
+	}
+	tpl, err := gotemplate.New("Validation").Funcs(template.FuncMap).Parse(template.VALIDATION_TEMPLATE)
+	if err != nil {
+		return err
+	}
+	files := make([]*descriptor.FileDescriptorProto, 0)
+	messages := make([]*messageInfo, 0)
+	for _, filename := range c.registry.Dependencies(c.Request.FileToGenerate) {
+		if file := c.registry.File(filename); c.isLocalFile(file) {
+			files = append(files, file)
+			messages = append(messages, c.registry.FileMessages(file)...)
+		}
+	}
+	needs := make(map[string]bool)
+	for name := range validations {
+		needs[name] = true
+	}
+	v := &validator{
+		goTypes:     goTypes{nativeTypes: native, native: c.Opts.NativeTypes()},
+		validations: validations,
+		needs:       holdingMessages(c.registry, messages, needs),
+	}
+
+	var errs GeneratorErrors
+	for _, file := range files {
+		validationFile, err := v.validationFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(validationFile.Kinds) == 0 && len(validationFile.Messages) == 0 {
+			continue
+		}
+		out := fmt.Sprintf("pkg/apis/%s/%s/%sValidation.go", strings.Replace(c.Opts.Group, ".", "", -1), file.GetPackage(), strings.TrimSuffix(path.Base(file.GetName()), ".proto"))
+		if err := c.runTemplate(out, tpl, validationFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", file.GetName(), err))
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// holdingMessages adds the messages holding one of needs to it, until no more are
+// found
+func holdingMessages(registry *registry, messages []*messageInfo, needs map[string]bool) map[string]bool {
+	for changed := true; changed; {
+		changed = false
+		for _, info := range messages {
+			name := qualifiedName(info.File.GetPackage(), info.Nested...)
+			if needs[name] {
+				continue
+			}
+			for _, field := range info.Message.GetField() {
+				if valueField, _ := registry.valueField(field); needs[valueField.GetTypeName()] {
+					needs[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return needs
+}
+
+// goName is the Go type of a message, which gogen prefixes for runtime objects
+// unless native types are generated
+func (g goTypes) goName(info *messageInfo) string {
+	return gogen.CamelCaseSlice(g.goPath(info))
+}
+
+func (g goTypes) goPath(info *messageInfo) []string {
+	if g.native {
+		return []string{g.messageName(info)}
+	}
+	nested := append([]string{}, info.Nested...)
+	if _, ok := g.kinds[qualifiedName(info.File.GetPackage(), nested[0])]; ok {
+		nested[0] = fmt.Sprintf(INTERNAL_FORMAT, nested[0])
+	}
+	return nested
+}
+
+// pointer tells whether a singular field is a pointer. Messages, proto2 fields
+// other than native required ones, native well known types and the native proto3
+// scalars with presence are.
+func (g goTypes) pointer(file *descriptor.FileDescriptorProto, field *descriptor.FieldDescriptorProto) bool {
+	isMessage := field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE
+	wellKnown, isWellKnown := wellKnownTypes[field.GetTypeName()]
+	bytes := field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES
+	required := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED
+	return (isMessage && !(g.native && isWellKnown && !wellKnown.Pointer)) || (file.GetSyntax() != "proto3" && !bytes && !(g.native && required)) || (g.native && g.presence(field))
+}
+
+// validationFile renders the functions of the messages of the file which need them
+func (v *validator) validationFile(file *descriptor.FileDescriptorProto) (*template.ValidationFile, error) {
+	v.file = file
+	v.imports = map[string]string{
+		"field":  "k8s.io/apimachinery/pkg/util/validation/field",
+		"fmt":    "fmt",
+		"regexp": "regexp",
+		"utf8":   "unicode/utf8",
+	}
+	v.patterns = nil
+	ret := &template.ValidationFile{
+		Package: file.GetPackage(),
+		Source:  file.GetName(),
+	}
+	var errs GeneratorErrors
+	code := []string{"field."}
+	for _, info := range v.registry.FileMessages(file) {
+		name := qualifiedName(file.GetPackage(), info.Nested...)
+		if kind, ok := v.kinds[name]; ok {
+			validation := template.KindValidation{Name: kind}
+			if v.needs[name] {
+				validation.Spec = v.goName(info)
+			}
+			ret.Kinds = append(ret.Kinds, validation)
+		}
+		if !v.needs[name] {
+			continue
+		}
+		message, err := v.message(info)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ret.Messages = append(ret.Messages, message)
+		code = append(code, message.Statements...)
+	}
+	ret.Patterns = v.patterns
+	if len(ret.Patterns) > 0 {
+		code = append(code, "regexp.")
+	}
+	ret.Imports = usedImports(v.imports, code)
+	return ret, errs.errorOrNil()
+}
+
+// message renders the validation of each field of the message
+func (v *validator) message(info *messageInfo) (template.MessageValidation, error) {
+	name := qualifiedName(info.File.GetPackage(), info.Nested...)
+	ret := template.MessageValidation{Name: v.goName(info)}
+	names := newProtoNames(info, v.goPath(info))
+	var errs GeneratorErrors
+	for _, field := range info.Message.GetField() {
+		validation := v.validations.get(name, field.GetName())
+		valueField, isMap := v.registry.valueField(field)
+		var validate string
+		if v.needs[valueField.GetTypeName()] {
+			target := v.registry.Message(valueField.GetTypeName())
+			var err error
+			if validate, err = v.qualify(v.file, target.File, "Validate"+v.goName(target), v.imports); err != nil {
+				errs = append(errs, fmt.Errorf("%s: message %s: field %s: %v", info.File.GetName(), strings.TrimPrefix(name, "."), field.GetName(), err))
+				continue
+			}
+		}
+		if validation == nil && validate == "" {
+			continue
+		}
+		if validation == nil {
+			validation = &fieldValidation{}
+		}
+		ret.Statements = append(ret.Statements, v.field(info, names, field, valueField, isMap, validation, validate)...)
+	}
+	return ret, errs.errorOrNil()
+}
+
+// field renders the statements validating the field. validate is the function
+// validating the message values of the field, if any.
+func (v *validator) field(info *messageInfo, names *protoNames, field, valueField *descriptor.FieldDescriptorProto, isMap bool, validation *fieldValidation, validate string) []string {
+	goField := gogen.CamelCase(field.GetName())
+	fldPath := fmt.Sprintf("fldPath.Child(%q)", nativeJSONName(field))
+	if !v.native {
+		goField = names.fields[field.GetName()]
+		fldPath = fmt.Sprintf("fldPath.Child(%q)", field.GetName())
+	}
+	in := "in." + goField
+	isMessage := valueField.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE
+
+	if field.OneofIndex != nil {
+		// Members are only validated when set
+		if v.native {
+			value := "*" + in
+			if isMessage {
+				value = in
+			}
+			return []string{fmt.Sprintf("if %s != nil {\n%s\n}", in, strings.Join(v.values(info, goField, value, fldPath, validation, validate), "\n"))}
+		}
+		oneof := names.oneofs[field.GetOneofIndex()]
+		wrapper := names.wrappers[field.GetName()]
+		fldPath = fmt.Sprintf("fldPath.Child(%q, %q)", gogen.CamelCase(info.Message.GetOneofDecl()[field.GetOneofIndex()].GetName()), gogen.CamelCase(field.GetName()))
+		return []string{fmt.Sprintf("if v, ok := in.%s.(*%s); ok {\n%s\n}", oneof, wrapper, strings.Join(v.values(info, goField, "v."+goField, fldPath, validation, validate), "\n"))}
+	}
+
+	statements := make([]string, 0)
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		if validation.Required {
+			statements = append(statements, fmt.Sprintf("if len(%s) == 0 {\nallErrs = append(allErrs, field.Required(%s, \"\"))\n}", in, fldPath))
+		}
+		if validation.MinItems != nil && *validation.MinItems > 1 {
+			// An empty list is left out of the JSON, like an unset field, so only longer
+			// minimums can fail
+			statements = append(statements, fmt.Sprintf("if n := len(%s); n > 0 && n < %d {\nallErrs = append(allErrs, field.Invalid(%s, n, \"must have at least %d items\"))\n}", in, *validation.MinItems, fldPath, *validation.MinItems))
+		}
+		if validation.MaxItems != nil {
+			statements = append(statements, fmt.Sprintf("if n := len(%s); n > %d {\nallErrs = append(allErrs, field.TooMany(%s, n, %d))\n}", in, *validation.MaxItems, fldPath, *validation.MaxItems))
+		}
+		elementPath := fldPath + ".Index(i)"
+		loop := fmt.Sprintf("for i, v := range %s", in)
+		value := "v"
+		if isMap {
+			key := "k"
+			if entryKey := v.registry.Message(field.GetTypeName()).Message.GetField()[0]; entryKey.GetType() != descriptor.FieldDescriptorProto_TYPE_STRING {
+				key = "fmt.Sprint(k)"
+			}
+			elementPath = fldPath + ".Key(" + key + ")"
+			loop = fmt.Sprintf("for k, v := range %s", in)
+		}
+		if isMessage && v.native {
+			// Native lists and maps hold the messages themselves
+			value = "&v"
+		}
+		if values := v.values(info, goField, value, elementPath, validation, validate); len(values) > 0 {
+			statements = append(statements, fmt.Sprintf("%s {\n%s\n}", loop, strings.Join(values, "\n")))
+		}
+		return statements
+	}
+
+	bytes := field.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES
+	required := field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED
+	pointer := v.pointer(v.file, field)
+	absent := fmt.Sprintf("%s == nil", in)
+	present := fmt.Sprintf("%s != nil", in)
+	switch {
+	case pointer:
+	case v.native && required:
+		// Always serialized, so never missing
+		absent, present = "", ""
+	case bytes, isMessage:
+		absent, present = fmt.Sprintf("len(%s) == 0", in), fmt.Sprintf("len(%s) != 0", in)
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL:
+		absent, present = "!"+in, in
+	case field.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING,
+		field.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM && v.native:
+		absent, present = fmt.Sprintf("%s == \"\"", in), fmt.Sprintf("%s != \"\"", in)
+	default:
+		absent, present = fmt.Sprintf("%s == 0", in), fmt.Sprintf("%s != 0", in)
+	}
+	if validation.Required && absent != "" {
+		statements = append(statements, fmt.Sprintf("if %s {\nallErrs = append(allErrs, field.Required(%s, \"\"))\n}", absent, fldPath))
+	}
+	value := in
+	if pointer && !isMessage {
+		value = "*" + in
+	}
+	values := v.values(info, goField, value, fldPath, validation, validate)
+	switch {
+	case len(values) == 0:
+	case isMessage || present == "":
+		statements = append(statements, values...)
+	default:
+		// Zero values are left out of the JSON and not validated by the CRD either
+		statements = append(statements, fmt.Sprintf("if %s {\n%s\n}", present, strings.Join(values, "\n")))
+	}
+	return statements
+}
+
+// values renders the statements validating a single value of a field
+func (v *validator) values(info *messageInfo, goField string, value string, fldPath string, validation *fieldValidation, validate string) []string {
+	statements := make([]string, 0)
+	invalid := func(condition string, detail string) {
+		statements = append(statements, fmt.Sprintf("if %s {\nallErrs = append(allErrs, field.Invalid(%s, %s, %s))\n}", condition, fldPath, value, strconv.Quote(detail)))
+	}
+	if validate != "" {
+		statements = append(statements, fmt.Sprintf("allErrs = append(allErrs, %s(%s, %s)...)", validate, value, fldPath))
+	}
+	if validation.Minimum != nil && !(validation.Unsigned && *validation.Minimum == 0) {
+		limit := strconv.FormatFloat(*validation.Minimum, 'g', -1, 64)
+		invalid(fmt.Sprintf("%s < %s", value, limit), "must be greater than or equal to "+limit)
+	}
+	if validation.Maximum != nil {
+		limit := strconv.FormatFloat(*validation.Maximum, 'g', -1, 64)
+		invalid(fmt.Sprintf("%s > %s", value, limit), "must be less than or equal to "+limit)
+	}
+	if validation.Pattern != "" {
+		name := "pattern" + v.goName(info) + goField
+		expr := strconv.Quote(validation.Pattern)
+		if !strings.Contains(validation.Pattern, "`") {
+			expr = "`" + validation.Pattern + "`"
+		}
+		v.patterns = append(v.patterns, template.ValidationPattern{Name: name, Expr: expr})
+		invalid(fmt.Sprintf("!%s.MatchString(%s)", name, value), "must match the pattern "+validation.Pattern)
+	}
+	if validation.MinLength != nil {
+		invalid(fmt.Sprintf("utf8.RuneCountInString(%s) < %d", value, *validation.MinLength), fmt.Sprintf("must be at least %d characters", *validation.MinLength))
+	}
+	if validation.MaxLength != nil {
+		statements = append(statements, fmt.Sprintf("if utf8.RuneCountInString(%s) > %d {\nallErrs = append(allErrs, field.TooLong(%s, %s, %d))\n}", value, *validation.MaxLength, fldPath, value, *validation.MaxLength))
+	}
+	if validation.Enum != nil {
+		supported := make([]string, 0, len(validation.Enum))
+		for _, value := range validation.EnumValues {
+			supported = append(supported, strconv.Quote(fmt.Sprint(value)))
+		}
+		statements = append(statements, fmt.Sprintf("switch %s {\ncase %s:\ndefault:\nallErrs = append(allErrs, field.NotSupported(%s, %s, []string{%s}))\n}", value, strings.Join(validation.Enum, ", "), fldPath, value, strings.Join(supported, ", ")))
+	}
+	return statements
+}
@@ -0,0 +1,443 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// testField declares a singular proto3 field, or a proto2 optional one
+func testField(name string, number int32, fieldType descriptor.FieldDescriptorProto_Type, typeName string) *descriptor.FieldDescriptorProto {
+	field := &descriptor.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     fieldType.Enum(),
+	}
+	if typeName != "" {
+		field.TypeName = proto.String(typeName)
+	}
+	return field
+}
+
+func withLabel(field *descriptor.FieldDescriptorProto, label descriptor.FieldDescriptorProto_Label) *descriptor.FieldDescriptorProto {
+	field.Label = label.Enum()
+	return field
+}
+
+// testRegistry indexes a proto3 file of package v1 declaring the message Spec and
+// a proto2 file of package v2 declaring Legacy
+func testRegistry() *registry {
+	v1 := &descriptor.FileDescriptorProto{
+		Name:    proto.String("v1.proto"),
+		Package: proto.String("v1"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptor.EnumDescriptorProto{{
+			Name: proto.String("Color"),
+			Value: []*descriptor.EnumValueDescriptorProto{
+				{Name: proto.String("RED"), Number: proto.Int32(0)},
+				{Name: proto.String("BLUE"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("Spec"),
+			Field: []*descriptor.FieldDescriptorProto{
+				testField("name", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+				testField("count", 2, descriptor.FieldDescriptorProto_TYPE_INT32, ""),
+				testField("size", 3, descriptor.FieldDescriptorProto_TYPE_UINT32, ""),
+				testField("ratio", 4, descriptor.FieldDescriptorProto_TYPE_FLOAT, ""),
+				withLabel(testField("tags", 5, descriptor.FieldDescriptorProto_TYPE_STRING, ""), descriptor.FieldDescriptorProto_LABEL_REPEATED),
+				withLabel(testField("labels", 6, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".v1.Spec.LabelsEntry"), descriptor.FieldDescriptorProto_LABEL_REPEATED),
+				testField("color", 7, descriptor.FieldDescriptorProto_TYPE_ENUM, ".v1.Color"),
+				testField("on", 8, descriptor.FieldDescriptorProto_TYPE_BOOL, ""),
+				{
+					Name:       proto.String("choice_a"),
+					JsonName:   proto.String("choiceA"),
+					Number:     proto.Int32(9),
+					Label:      descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:       descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+					OneofIndex: proto.Int32(0),
+				},
+				testField("blob", 10, descriptor.FieldDescriptorProto_TYPE_BYTES, ""),
+				testField("spec", 11, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".v1.Spec"),
+			},
+			NestedType: []*descriptor.DescriptorProto{{
+				Name: proto.String("LabelsEntry"),
+				Field: []*descriptor.FieldDescriptorProto{
+					testField("key", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+					testField("value", 2, descriptor.FieldDescriptorProto_TYPE_INT64, ""),
+				},
+				Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+			OneofDecl: []*descriptor.OneofDescriptorProto{{Name: proto.String("choice")}},
+		}},
+	}
+	v2 := &descriptor.FileDescriptorProto{
+		Name:    proto.String("v2.proto"),
+		Package: proto.String("v2"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("Legacy"),
+			Field: []*descriptor.FieldDescriptorProto{
+				testField("replicas", 1, descriptor.FieldDescriptorProto_TYPE_INT32, ""),
+				withLabel(testField("id", 2, descriptor.FieldDescriptorProto_TYPE_STRING, ""), descriptor.FieldDescriptorProto_LABEL_REQUIRED),
+				testField("flag", 3, descriptor.FieldDescriptorProto_TYPE_BOOL, ""),
+			},
+		}},
+	}
+	return newRegistry([]*descriptor.FileDescriptorProto{v1, v2})
+}
+
+// testMessageField returns the field of the message with the fully qualified name
+func testMessageField(r *registry, message string, name string) *descriptor.FieldDescriptorProto {
+	for _, field := range r.Message(message).Message.GetField() {
+		if field.GetName() == name {
+			return field
+		}
+	}
+	panic("no field " + name + " in " + message)
+}
+
+func TestParseFieldValidation(t *testing.T) {
+	r := testRegistry()
+	tests := []struct {
+		field    string
+		comments []string
+		want     *fieldValidation
+		// err is a part of the expected error, empty when parsing succeeds
+		err string
+	}{
+		{
+			field:    "name",
+			comments: []string{" the name", ""},
+		},
+		{
+			field:    "name",
+			comments: []string{" +drekle:k8s:pattern=^[a-z]+$", " +drekle:k8s:minLength=1", " +drekle:k8s:maxLength=10", " +drekle:k8s:required"},
+			want:     &fieldValidation{Pattern: "^[a-z]+$", MinLength: int64Ptr(1), MaxLength: int64Ptr(10), Required: true},
+		},
+		{
+			field:    "name",
+			comments: []string{" +drekle:k8s:enum=a, b,,c"},
+			want:     &fieldValidation{Enum: []string{`"a"`, `"b"`, `"c"`}, EnumValues: []interface{}{"a", "b", "c"}},
+		},
+		{
+			field:    "count",
+			comments: []string{" +drekle:k8s:minimum=-1", " +drekle:k8s:maximum=5"},
+			want:     &fieldValidation{Minimum: float64Ptr(-1), Maximum: float64Ptr(5)},
+		},
+		{
+			field:    "count",
+			comments: []string{" +drekle:k8s:enum=1,2"},
+			want:     &fieldValidation{Enum: []string{"1", "2"}, EnumValues: []interface{}{int64(1), int64(2)}},
+		},
+		{
+			field:    "size",
+			comments: []string{" +drekle:k8s:maximum=3"},
+			want:     &fieldValidation{Maximum: float64Ptr(3), Unsigned: true},
+		},
+		{
+			field:    "ratio",
+			comments: []string{" +drekle:k8s:minimum=0.5", " +drekle:k8s:enum=0.5,1"},
+			want:     &fieldValidation{Minimum: float64Ptr(0.5), Enum: []string{"0.5", "1"}, EnumValues: []interface{}{0.5, float64(1)}},
+		},
+		{
+			field:    "tags",
+			comments: []string{" +drekle:k8s:minItems=1", " +drekle:k8s:maxItems=3", " +drekle:k8s:maxLength=8"},
+			want:     &fieldValidation{MinItems: int64Ptr(1), MaxItems: int64Ptr(3), MaxLength: int64Ptr(8)},
+		},
+		{
+			field:    "labels",
+			comments: []string{" +drekle:k8s:minimum=0"},
+			want:     &fieldValidation{Minimum: float64Ptr(0)},
+		},
+		{
+			field:    "labels",
+			comments: []string{" +drekle:k8s:maxItems=3"},
+			err:      "invalid +drekle:k8s:maxItems annotation `3`: only repeated fields have items",
+		},
+		{
+			field:    "count",
+			comments: []string{" +drekle:k8s:minimum=1.5"},
+			err:      "1.5 is not an integer",
+		},
+		{
+			field:    "size",
+			comments: []string{" +drekle:k8s:minimum=-1"},
+			err:      "-1 is negative but the field is unsigned",
+		},
+		{
+			field:    "count",
+			comments: []string{" +drekle:k8s:minimum=5", " +drekle:k8s:maximum=1"},
+			err:      "minimum 5 exceeds the maximum 1",
+		},
+		{
+			field:    "name",
+			comments: []string{" +drekle:k8s:minLength=3", " +drekle:k8s:maxLength=2"},
+			err:      "minimum length 3 exceeds the maximum length 2",
+		},
+		{
+			field:    "name",
+			comments: []string{" +drekle:k8s:minimum=1"},
+			err:      "only numeric fields have a minimum or maximum",
+		},
+		{
+			field:    "count",
+			comments: []string{" +drekle:k8s:pattern=^a$"},
+			err:      "only string fields have a pattern",
+		},
+		{
+			field:    "name",
+			comments: []string{" +drekle:k8s:pattern=[a-"},
+			err:      "invalid +drekle:k8s:pattern annotation `[a-`",
+		},
+		{
+			field:    "name",
+			comments: []string{" +drekle:k8s:maxLength=-1"},
+			err:      "must not be negative",
+		},
+		{
+			field:    "on",
+			comments: []string{" +drekle:k8s:enum=true"},
+			err:      "only string and numeric fields have an enum",
+		},
+		{
+			field:    "name",
+			comments: []string{" +drekle:k8s:enum=,"},
+			err:      "at least one value is required",
+		},
+		{
+			field:    "name",
+			comments: []string{" +drekle:k8s:required=true"},
+			err:      "takes no value",
+		},
+		{
+			field:    "choice_a",
+			comments: []string{" +drekle:k8s:required"},
+			err:      "oneof members cannot be required",
+		},
+	}
+	for _, test := range tests {
+		name := test.field + ":" + strings.Join(test.comments, ",")
+		t.Run(name, func(t *testing.T) {
+			validation, err := r.parseFieldValidation(testMessageField(r, ".v1.Spec", test.field), test.comments)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("parseFieldValidation error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFieldValidation error = %v", err)
+			}
+			if !reflect.DeepEqual(validation, test.want) {
+				t.Errorf("parseFieldValidation = %+v, want %+v", validation, test.want)
+			}
+		})
+	}
+}
+
+func TestFieldValidationApply(t *testing.T) {
+	validation := &fieldValidation{
+		Minimum:    float64Ptr(1),
+		Maximum:    float64Ptr(5),
+		Pattern:    "^a",
+		MinLength:  int64Ptr(1),
+		MaxLength:  int64Ptr(2),
+		MinItems:   int64Ptr(1),
+		MaxItems:   int64Ptr(3),
+		EnumValues: []interface{}{"a"},
+	}
+	values := func(schema JSONSchemaProps) *JSONSchemaProps {
+		schema.Minimum, schema.Maximum = float64Ptr(1), float64Ptr(5)
+		schema.Pattern = "^a"
+		schema.MinLength, schema.MaxLength = int64Ptr(1), int64Ptr(2)
+		schema.Enum = []interface{}{"a"}
+		return &schema
+	}
+	tests := []struct {
+		name   string
+		schema *JSONSchemaProps
+		want   *JSONSchemaProps
+	}{
+		{"value", &JSONSchemaProps{Type: "string"}, values(JSONSchemaProps{Type: "string"})},
+		{"list", &JSONSchemaProps{Type: "array", Items: &JSONSchemaProps{Type: "string"}}, &JSONSchemaProps{
+			Type:     "array",
+			Items:    values(JSONSchemaProps{Type: "string"}),
+			MinItems: int64Ptr(1),
+			MaxItems: int64Ptr(3),
+		}},
+		{"map", &JSONSchemaProps{Type: "object", AdditionalProperties: &JSONSchemaProps{Type: "string"}}, &JSONSchemaProps{
+			Type:                 "object",
+			AdditionalProperties: values(JSONSchemaProps{Type: "string"}),
+		}},
+	}
+	for _, test := range tests {
+		validation.apply(test.schema)
+		if !reflect.DeepEqual(test.schema, test.want) {
+			t.Errorf("%s: apply = %+v, want %+v", test.name, test.schema, test.want)
+		}
+	}
+
+	// The minimum of unsigned fields is kept
+	schema := &JSONSchemaProps{Type: "integer", Minimum: float64Ptr(0)}
+	(&fieldValidation{Maximum: float64Ptr(5), Unsigned: true}).apply(schema)
+	if want := (&JSONSchemaProps{Type: "integer", Minimum: float64Ptr(0), Maximum: float64Ptr(5)}); !reflect.DeepEqual(schema, want) {
+		t.Errorf("apply = %+v, want %+v", schema, want)
+	}
+}
+
+func TestValidatorField(t *testing.T) {
+	r := testRegistry()
+	tests := []struct {
+		native     bool
+		message    string
+		field      string
+		validation *fieldValidation
+		want       string
+	}{
+		{
+			native:     true,
+			message:    ".v1.Spec",
+			field:      "name",
+			validation: &fieldValidation{Required: true, MaxLength: int64Ptr(10)},
+			want: `if in.Name == "" {
+allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+}
+if in.Name != "" {
+if utf8.RuneCountInString(in.Name) > 10 {
+allErrs = append(allErrs, field.TooLong(fldPath.Child("name"), in.Name, 10))
+}
+}`,
+		},
+		{
+			message:    ".v1.Spec",
+			field:      "count",
+			validation: &fieldValidation{Minimum: float64Ptr(1)},
+			want: `if in.Count != 0 {
+if in.Count < 1 {
+allErrs = append(allErrs, field.Invalid(fldPath.Child("count"), in.Count, "must be greater than or equal to 1"))
+}
+}`,
+		},
+		{
+			native:     true,
+			message:    ".v1.Spec",
+			field:      "tags",
+			validation: &fieldValidation{MinItems: int64Ptr(2), MaxItems: int64Ptr(3)},
+			want: `if n := len(in.Tags); n > 0 && n < 2 {
+allErrs = append(allErrs, field.Invalid(fldPath.Child("tags"), n, "must have at least 2 items"))
+}
+if n := len(in.Tags); n > 3 {
+allErrs = append(allErrs, field.TooMany(fldPath.Child("tags"), n, 3))
+}`,
+		},
+		{
+			native:     true,
+			message:    ".v1.Spec",
+			field:      "labels",
+			validation: &fieldValidation{Maximum: float64Ptr(9)},
+			want: `for k, v := range in.Labels {
+if v > 9 {
+allErrs = append(allErrs, field.Invalid(fldPath.Child("labels").Key(k), v, "must be less than or equal to 9"))
+}
+}`,
+		},
+		{
+			native:     true,
+			message:    ".v1.Spec",
+			field:      "choice_a",
+			validation: &fieldValidation{MaxLength: int64Ptr(2)},
+			want: `if in.ChoiceA != nil {
+if utf8.RuneCountInString(*in.ChoiceA) > 2 {
+allErrs = append(allErrs, field.TooLong(fldPath.Child("choiceA"), *in.ChoiceA, 2))
+}
+}`,
+		},
+		{
+			message:    ".v1.Spec",
+			field:      "choice_a",
+			validation: &fieldValidation{MaxLength: int64Ptr(2)},
+			want: `if v, ok := in.Choice.(*Spec_ChoiceA); ok {
+if utf8.RuneCountInString(v.ChoiceA) > 2 {
+allErrs = append(allErrs, field.TooLong(fldPath.Child("Choice", "ChoiceA"), v.ChoiceA, 2))
+}
+}`,
+		},
+		{
+			native:     true,
+			message:    ".v1.Spec",
+			field:      "color",
+			validation: &fieldValidation{Enum: []string{"Color_RED"}, EnumValues: []interface{}{"RED"}},
+			want: `if in.Color != "" {
+switch in.Color {
+case Color_RED:
+default:
+allErrs = append(allErrs, field.NotSupported(fldPath.Child("color"), in.Color, []string{"RED"}))
+}
+}`,
+		},
+		{
+			native:     true,
+			message:    ".v2.Legacy",
+			field:      "id",
+			validation: &fieldValidation{Required: true, MinLength: int64Ptr(1)},
+			want: `if utf8.RuneCountInString(in.Id) < 1 {
+allErrs = append(allErrs, field.Invalid(fldPath.Child("id"), in.Id, "must be at least 1 characters"))
+}`,
+		},
+		{
+			message:    ".v2.Legacy",
+			field:      "replicas",
+			validation: &fieldValidation{Required: true, Maximum: float64Ptr(5)},
+			want: `if in.Replicas == nil {
+allErrs = append(allErrs, field.Required(fldPath.Child("replicas"), ""))
+}
+if in.Replicas != nil {
+if *in.Replicas > 5 {
+allErrs = append(allErrs, field.Invalid(fldPath.Child("replicas"), *in.Replicas, "must be less than or equal to 5"))
+}
+}`,
+		},
+	}
+	for _, test := range tests {
+		info := r.Message(test.message)
+		v := &validator{goTypes: goTypes{nativeTypes: testNativeTypes(r), native: test.native}, file: info.File, imports: make(map[string]string)}
+		field := testMessageField(r, test.message, test.field)
+		valueField, isMap := r.valueField(field)
+		got := strings.Join(v.field(info, newProtoNames(info, v.goPath(info)), field, valueField, isMap, test.validation, ""), "\n")
+		if got != test.want {
+			t.Errorf("native %v: %s:\n%s\nwant\n%s", test.native, test.field, got, test.want)
+		}
+	}
+}
+
+func TestValidatorNestedMessages(t *testing.T) {
+	r := testRegistry()
+	info := r.Message(".v1.Spec")
+	v := &validator{
+		goTypes:     goTypes{nativeTypes: testNativeTypes(r), native: true},
+		validations: fieldValidations{".v1.Spec": {"name": {Pattern: "^a"}}},
+		needs:       holdingMessages(r, r.FileMessages(info.File), map[string]bool{".v1.Spec": true}),
+		file:        info.File,
+		imports:     make(map[string]string),
+	}
+	message, err := v.message(info)
+	if err != nil {
+		t.Fatalf("message error = %v", err)
+	}
+	want := []string{
+		"if in.Name != \"\" {\nif !patternSpecName.MatchString(in.Name) {\nallErrs = append(allErrs, field.Invalid(fldPath.Child(\"name\"), in.Name, \"must match the pattern ^a\"))\n}\n}",
+		"allErrs = append(allErrs, ValidateSpec(in.Spec, fldPath.Child(\"spec\"))...)",
+	}
+	if !reflect.DeepEqual(message.Statements, want) {
+		t.Errorf("message statements = %q, want %q", message.Statements, want)
+	}
+	if len(v.patterns) != 1 || v.patterns[0].Name != "patternSpecName" || v.patterns[0].Expr != "`^a`" {
+		t.Errorf("patterns = %+v", v.patterns)
+	}
+}
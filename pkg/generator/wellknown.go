@@ -0,0 +1,208 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/drekle/protoc-gen-k8s/pkg/template"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// wellKnownType is the native Go type of a google.protobuf message. golang/protobuf
+// types neither deep copy nor serialize the way Kubernetes objects do, so native
+// types use the Kubernetes equivalent instead.
+type wellKnownType struct {
+	// GoType is the type of a single value, declared in the package of Import
+	GoType string
+	Import *template.NativeImport
+	// Pointer is set when singular fields hold a pointer to GoType
+	Pointer bool
+	// Schema builds the CRD schema of a value
+	Schema func() *JSONSchemaProps
+	// Helper prefixes the <Helper>ToProto and <Helper>FromProto functions of pkg/convert,
+	// which also return an error when HelperErr is set
+	Helper    string
+	HelperErr bool
+	// ProtoType is the golang/protobuf type, declared in the package of ProtoImport
+	ProtoType   string
+	ProtoImport *template.NativeImport
+	// ProtoSample is a value of ProtoType for the generated round trip tests
+	ProtoSample string
+}
+
+var (
+	timestampImport    = &template.NativeImport{Alias: "timestamppb", Path: "google.golang.org/protobuf/types/known/timestamppb"}
+	durationImport     = &template.NativeImport{Alias: "durationpb", Path: "google.golang.org/protobuf/types/known/durationpb"}
+	structImport       = &template.NativeImport{Alias: "structpb", Path: "google.golang.org/protobuf/types/known/structpb"}
+	anyImport          = &template.NativeImport{Alias: "anypb", Path: "google.golang.org/protobuf/types/known/anypb"}
+	wrappersImport     = &template.NativeImport{Alias: "wrapperspb", Path: "google.golang.org/protobuf/types/known/wrapperspb"}
+	metav1Import       = &template.NativeImport{Alias: "metav1", Path: "k8s.io/apimachinery/pkg/apis/meta/v1"}
+	runtimeImport      = &template.NativeImport{Alias: "runtime", Path: "k8s.io/apimachinery/pkg/runtime"}
+	apiextensionImport = &template.NativeImport{Alias: "apiextensionsv1beta1", Path: "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"}
+)
+
+// wellKnownTypes maps the fully qualified google.protobuf messages to their native type
+var wellKnownTypes = map[string]wellKnownType{
+	".google.protobuf.Timestamp": {
+		GoType:  "metav1.Time",
+		Import:  metav1Import,
+		Pointer: true,
+		Schema: func() *JSONSchemaProps {
+			return &JSONSchemaProps{Type: "string", Format: "date-time"}
+		},
+		Helper:      "Time",
+		ProtoType:   "timestamppb.Timestamp",
+		ProtoImport: timestampImport,
+		ProtoSample: "&timestamppb.Timestamp{Seconds: 1}",
+	},
+	".google.protobuf.Duration": {
+		GoType:  "metav1.Duration",
+		Import:  metav1Import,
+		Pointer: true,
+		Schema: func() *JSONSchemaProps {
+			return &JSONSchemaProps{Type: "string", Description: "A duration such as 1h30m"}
+		},
+		Helper:      "Duration",
+		ProtoType:   "durationpb.Duration",
+		ProtoImport: durationImport,
+		ProtoSample: "&durationpb.Duration{Seconds: 90}",
+	},
+	".google.protobuf.Struct": {
+		GoType:  "apiextensionsv1beta1.JSON",
+		Import:  apiextensionImport,
+		Pointer: true,
+		Schema: func() *JSONSchemaProps {
+			return &JSONSchemaProps{Type: "object", PreserveUnknown: boolPtr(true)}
+		},
+		Helper:      "Struct",
+		HelperErr:   true,
+		ProtoType:   "structpb.Struct",
+		ProtoImport: structImport,
+		ProtoSample: `&structpb.Struct{Fields: map[string]*structpb.Value{"a": {Kind: &structpb.Value_StringValue{StringValue: "a"}}}}`,
+	},
+	".google.protobuf.Value": {
+		GoType:  "apiextensionsv1beta1.JSON",
+		Import:  apiextensionImport,
+		Pointer: true,
+		Schema: func() *JSONSchemaProps {
+			return &JSONSchemaProps{PreserveUnknown: boolPtr(true)}
+		},
+		Helper:      "Value",
+		HelperErr:   true,
+		ProtoType:   "structpb.Value",
+		ProtoImport: structImport,
+		ProtoSample: "&structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: true}}",
+	},
+	".google.protobuf.ListValue": {
+		GoType:  "apiextensionsv1beta1.JSON",
+		Import:  apiextensionImport,
+		Pointer: true,
+		Schema: func() *JSONSchemaProps {
+			return &JSONSchemaProps{Type: "array", Items: &JSONSchemaProps{PreserveUnknown: boolPtr(true)}}
+		},
+		Helper:      "ListValue",
+		HelperErr:   true,
+		ProtoType:   "structpb.ListValue",
+		ProtoImport: structImport,
+		ProtoSample: "&structpb.ListValue{Values: []*structpb.Value{{Kind: &structpb.Value_NumberValue{NumberValue: 1}}}}",
+	},
+	".google.protobuf.Any": {
+		GoType:  "runtime.RawExtension",
+		Import:  runtimeImport,
+		Pointer: true,
+		Schema: func() *JSONSchemaProps {
+			return &JSONSchemaProps{Type: "object", PreserveUnknown: boolPtr(true)}
+		},
+		Helper:      "Any",
+		HelperErr:   true,
+		ProtoType:   "anypb.Any",
+		ProtoImport: anyImport,
+		// A StringValue holding "a", which resolves since the wrappers are registered
+		ProtoSample: `&anypb.Any{TypeUrl: "type.googleapis.com/google.protobuf.StringValue", Value: []byte{0x0a, 0x01, 0x61}}`,
+	},
+	".google.protobuf.DoubleValue": wrapperType("DoubleValue", "float64", &JSONSchemaProps{Type: "number", Format: "double"}, "1.5"),
+	".google.protobuf.FloatValue":  wrapperType("FloatValue", "float32", &JSONSchemaProps{Type: "number", Format: "float"}, "1.5"),
+	".google.protobuf.Int64Value":  wrapperType("Int64Value", "int64", &JSONSchemaProps{Type: "integer", Format: "int64"}, "1"),
+	".google.protobuf.UInt64Value": wrapperType("UInt64Value", "uint64", &JSONSchemaProps{Type: "integer", Minimum: float64Ptr(0)}, "1"),
+	".google.protobuf.Int32Value":  wrapperType("Int32Value", "int32", &JSONSchemaProps{Type: "integer", Format: "int32"}, "1"),
+	".google.protobuf.UInt32Value": wrapperType("UInt32Value", "uint32", &JSONSchemaProps{Type: "integer", Minimum: float64Ptr(0)}, "1"),
+	".google.protobuf.BoolValue":   wrapperType("BoolValue", "bool", &JSONSchemaProps{Type: "boolean"}, "true"),
+	".google.protobuf.StringValue": wrapperType("StringValue", "string", &JSONSchemaProps{Type: "string"}, "\"a\""),
+	".google.protobuf.BytesValue":  wrapperType("BytesValue", "[]byte", &JSONSchemaProps{Type: "string", Format: "byte"}, "[]byte(\"a\")"),
+}
+
+// wrapperType maps a wrapper to a pointer to its value, so that unset stays apart
+// from the zero value. A nil slice already does so for bytes.
+func wrapperType(name string, goType string, schema *JSONSchemaProps, sample string) wellKnownType {
+	return wellKnownType{
+		GoType:  goType,
+		Pointer: !strings.HasPrefix(goType, "[]"),
+		Schema: func() *JSONSchemaProps {
+			copied := *schema
+			return &copied
+		},
+		Helper:      name,
+		ProtoType:   "wrapperspb." + name,
+		ProtoImport: wrappersImport,
+		ProtoSample: fmt.Sprintf("&wrapperspb.%s{Value: %s}", name, sample),
+	}
+}
+
+// wellKnownOpts lists the messages converted by the pkg/convert template
+func wellKnownOpts() template.WellKnownOpts {
+	var opts template.WellKnownOpts
+	for _, wellKnown := range wellKnownTypes {
+		switch wellKnown.Import {
+		case nil:
+			// Only the wrappers map to builtin types
+			opts.Wrappers = append(opts.Wrappers, template.WrapperType{Name: wellKnown.Helper, GoType: wellKnown.GoType, Pointer: wellKnown.Pointer})
+		case apiextensionImport:
+			opts.JSON = append(opts.JSON, wellKnown.Helper)
+		}
+	}
+	sort.Slice(opts.Wrappers, func(i, j int) bool {
+		return opts.Wrappers[i].Name < opts.Wrappers[j].Name
+	})
+	sort.Strings(opts.JSON)
+	return opts
+}
+
+// protobufFields checks that the golang/protobuf messages generated in protobuf mode
+// hold every field of the local messages
+func (c *controllerGenerator) protobufFields() error {
+	if c.Opts.NativeTypes() {
+		return nil
+	}
+	var errs GeneratorErrors
+	for _, filename := range c.registry.Dependencies(c.Request.FileToGenerate) {
+		file := c.registry.File(filename)
+		if !c.isLocalFile(file) {
+			continue
+		}
+		for _, info := range c.registry.FileMessages(file) {
+			name := qualifiedName(file.GetPackage(), info.Nested...)
+			for _, field := range info.Message.GetField() {
+				if err := c.registry.protobufField(field); err != nil {
+					errs = append(errs, fmt.Errorf("%s: message %s: field %s: %v", filename, strings.TrimPrefix(name, "."), field.GetName(), err))
+				}
+			}
+		}
+	}
+	return errs.errorOrNil()
+}
+
+// protobufField rejects the fields golang/protobuf messages cannot hold in a
+// Kubernetes object. deepcopy-gen cannot copy the golang/protobuf well-known types,
+// which only native types map to Kubernetes types, and protoc-gen-go v1.3 drops the
+// presence of proto3 optional scalars, which only native types keep.
+func (r *registry) protobufField(field *descriptor.FieldDescriptorProto) error {
+	if r.Optional(field) && field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return fmt.Errorf("golang/protobuf messages cannot tell an unset proto3 optional field from a zero one, use types=native to generate a pointer")
+	}
+	value, _ := r.valueField(field)
+	if _, ok := wellKnownTypes[value.GetTypeName()]; ok && value.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return fmt.Errorf("golang/protobuf messages cannot deep copy %s, use types=native to map it to a Kubernetes type", strings.TrimPrefix(value.GetTypeName(), "."))
+	}
+	return nil
+}
@@ -1,8 +1,16 @@
 package template
 
 type CobraRootOpts struct {
-	Name            string
-	ControllerNames []string
+	Name    string
+	RepoURL string
+	// Group is the API group without dots, naming the directory of the API packages
+	Group string
+	// Packages lists the API packages registered with the controller-runtime scheme
+	Packages []string
+	// Controllers lists every runtime object across all input files
+	Controllers []TemplateOpts
+	// Namespaced is set when any controller watches a namespaced kind
+	Namespaced bool
 }
 
 var CobraRootTemplate = `package main
@@ -12,6 +20,9 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
+
+	"{{ .RepoURL }}/pkg/controller"
 
 	"github.com/spf13/cobra"
 )
@@ -49,9 +60,63 @@ func NewCmdRoot(out io.Writer) *cobra.Command {
 
 	cmd := rootCommand.cobraCommand
 
-	{{ range $_, $value := .ControllerNames }}
-	cmd.AddCommand(NewCmd{{$value}}Controller(out))
-	{{ end }}
+	cmd.AddCommand(NewCmdRun(out))
+
+	return cmd
+}
+
+// NewCmdRun groups the controller subcommands, which share the client flags
+func NewCmdRun(out io.Writer) *cobra.Command {
+	s := controller.NewOpts()
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "start a controller",
+		Long:  "start a controller, or all of them with run all",
+	}
+
+	//Mostly for local debugging
+	cmd.PersistentFlags().StringVarP(&s.Kubeconfig, "kubeconfig", "k", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	cmd.PersistentFlags().StringVarP(&s.MasterURL, "master", "m", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	{{- if .Namespaced }}
+	cmd.PersistentFlags().StringVarP(&s.Namespace, "namespace", "n", "", "Only watch namespaced objects in this namespace. All namespaces are watched when empty.")
+	{{- end }}
+
+	cmd.PersistentFlags().BoolVar(&s.LeaderElect, "leader-elect", false, "Elect a leader among the replicas so that only one of them runs the controllers.")
+	cmd.PersistentFlags().StringVar(&s.LeaseNamespace, "leader-elect-resource-namespace", "default", "Namespace of the Lease used for leader election.")
+	cmd.PersistentFlags().StringVar(&s.LeaseName, "leader-elect-resource-name", "{{ .Name | ToLower }}-controller", "Name of the Lease used for leader election.")
+	cmd.PersistentFlags().DurationVar(&s.LeaseDuration, "leader-elect-lease-duration", 15*time.Second, "How long replicas wait before taking over from a leader which stopped renewing.")
+	cmd.PersistentFlags().DurationVar(&s.RenewDeadline, "leader-elect-renew-deadline", 10*time.Second, "How long the leader retries renewing before it gives up leadership.")
+	cmd.PersistentFlags().DurationVar(&s.RetryPeriod, "leader-elect-retry-period", 2*time.Second, "How long replicas wait between attempts to acquire or renew the Lease.")
+	cmd.PersistentFlags().StringVar(&s.Identity, "leader-elect-identity", "", "Identity of this replica in the Lease. Defaults to the hostname.")
+
+	cmd.PersistentFlags().StringVar(&s.MetricsAddr, "metrics-bind-address", ":8080", "The address /metrics is served on. Empty disables it.")
+	cmd.PersistentFlags().StringVar(&s.HealthAddr, "health-probe-bind-address", ":8081", "The address /healthz and /readyz are served on. Empty disables them.")
+	{{ range $_, $controller := .Controllers }}
+	cmd.AddCommand(NewCmd{{ $controller.Name }}Controller(out, s))
+	{{- end }}
+	cmd.AddCommand(NewCmdAllControllers(out, s))
+
+	return cmd
+}
+
+// NewCmdAllControllers starts every controller in one process
+func NewCmdAllControllers(out io.Writer, s *controller.Opts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "all",
+		Short:   "start every controller",
+		Long:    "start every controller in one process",
+		Example: "./{{ .Name }}Controller run all",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			s.Run(controller.ControllerNames...)
+		},
+	}
+
+	// The flags of each controller are prefixed with its name
+	{{- range $_, $controller := .Controllers }}
+	add{{ $controller.Name }}ControllerFlags(cmd.Flags(), &s.{{ $controller.Name }}, "{{ $controller.Singular }}-")
+	{{- end }}
 
 	return cmd
 }
@@ -69,32 +134,41 @@ import (
 	"{{ .RepoURL }}/pkg/controller"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
-	{{ .Name | ToLower }}ControllerLong    = "start the controller"
-	{{ .Name | ToLower }}ControllerExample = "./{{ .Name }}Controller run"
-	{{ .Name | ToLower }}ControllerShort   = "start the controller"
+	{{ .Name | ToLower }}ControllerLong    = "start the {{ .Name }} controller"
+	{{ .Name | ToLower }}ControllerExample = "./{{ .GroupGoName }}Controller run {{ .Singular }}"
+	{{ .Name | ToLower }}ControllerShort   = "start the {{ .Name }} controller"
 )
 
-func NewCmd{{ .Name }}Controller(out io.Writer) *cobra.Command {
-	s := &controller.{{ .Name }}Opts{}
-
+func NewCmd{{ .Name }}Controller(out io.Writer, s *controller.Opts) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "run",
+		Use:     "{{ .Singular }}",
+		{{- if .ShortNames }}
+		Aliases: []string{ {{- range $i, $name := .ShortNames }}{{ if $i }}, {{ end }}"{{ $name }}"{{ end -}} },
+		{{- end }}
 		Short:   {{ .Name | ToLower }}ControllerShort,
 		Long:    {{ .Name | ToLower }}ControllerLong,
 		Example: {{ .Name | ToLower }}ControllerExample,
+		Args:    cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			s.Run()
+			s.Run("{{ .Singular }}")
 		},
 	}
-
-	//Mostly for local debugging
-	cmd.Flags().StringVarP(&s.Kubeconfig, "kubeconfig", "k", "", "Path to a kubeconfig. Only required if out-of-cluster.")
-	cmd.Flags().StringVarP(&s.MasterURL, "master", "m", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	add{{ .Name }}ControllerFlags(cmd.Flags(), &s.{{ .Name }}, "")
 
 	return cmd
 }
 
+func add{{ .Name }}ControllerFlags(flags *pflag.FlagSet, o *controller.{{ .Name }}Opts, prefix string) {
+	flags.IntVar(&o.Workers, prefix+"workers", o.Workers, "Number of {{ .Name }} objects reconciled concurrently.")
+	flags.DurationVar(&o.ResyncPeriod, prefix+"resync-period", o.ResyncPeriod, "How often every {{ .Name }} is reconciled again without changes. 0 disables it.")
+	flags.DurationVar(&o.RateLimitBaseDelay, prefix+"rate-limit-base-delay", o.RateLimitBaseDelay, "First retry delay of a failing {{ .Name }}, doubled on every failure.")
+	flags.DurationVar(&o.RateLimitMaxDelay, prefix+"rate-limit-max-delay", o.RateLimitMaxDelay, "Longest retry delay of a failing {{ .Name }}.")
+	flags.Float64Var(&o.RateLimitQPS, prefix+"rate-limit-qps", o.RateLimitQPS, "Overall rate of {{ .Name }} retries per second.")
+	flags.IntVar(&o.RateLimitBurst, prefix+"rate-limit-burst", o.RateLimitBurst, "Overall burst of {{ .Name }} retries.")
+}
+
 `
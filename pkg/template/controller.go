@@ -1,8 +1,10 @@
 package template
 
 import (
+	"fmt"
 	"strings"
 	gotemplate "text/template"
+	"time"
 )
 
 type TemplateOpts struct {
@@ -11,6 +13,66 @@ type TemplateOpts struct {
 	Package     string
 	RepoURL     string
 	RuntimeType string
+	// StatusType is the Go type of the status subresource, if any
+	StatusType string
+	// GroupGoName is the name client-gen gives the group in the clientset
+	GroupGoName string
+	// GroupName is the full API group name, e.g. drekle.example.io
+	GroupName string
+	// ClusterScoped is set for kinds which are not namespaced
+	ClusterScoped bool
+	// Plural is the lower case resource name of the kind in API paths
+	Plural string
+	// Singular is the lower case resource name of the kind, which also names its run subcommand
+	Singular   string
+	ShortNames []string
+	Defaults   ControllerDefaults
+	// Owns lists the secondary resources the controller creates for each object
+	Owns []OwnedResource
+	// ControllerRuntime is set when the project is generated for controller-runtime
+	ControllerRuntime bool
+	// NativeTypes is set when the kinds use native types rather than proto messages
+	NativeTypes bool
+}
+
+// OwnedResource is a built-in Kubernetes kind owned by a runtime object
+type OwnedResource struct {
+	// Group is the API group, empty for the core group
+	Group   string
+	Version string
+	Kind    string
+	// GroupGoName and VersionGoName select the informer in the client-go informer factory
+	GroupGoName   string
+	VersionGoName string
+	// Resource is the lower case plural resource name
+	Resource string
+}
+
+// ControllerDefaults are the defaults of the <Name>Opts flags of a controller
+type ControllerDefaults struct {
+	Workers            int
+	ResyncPeriod       time.Duration
+	RateLimitBaseDelay time.Duration
+	RateLimitMaxDelay  time.Duration
+	RateLimitQPS       float64
+	RateLimitBurst     int
+}
+
+// DEFAULT_CONTROLLER_DEFAULTS match workqueue.DefaultControllerRateLimiter and the
+// former hardcoded resync period
+var DEFAULT_CONTROLLER_DEFAULTS = ControllerDefaults{
+	Workers:            1,
+	ResyncPeriod:       time.Minute,
+	RateLimitBaseDelay: 5 * time.Millisecond,
+	RateLimitMaxDelay:  1000 * time.Second,
+	RateLimitQPS:       10,
+	RateLimitBurst:     100,
+}
+
+// ControllerRunOpts lists every controller generated into the controller package
+type ControllerRunOpts struct {
+	RepoURL     string
+	Controllers []TemplateOpts
 }
 
 var FuncMap = gotemplate.FuncMap{
@@ -19,6 +81,56 @@ var FuncMap = gotemplate.FuncMap{
 	"PackageName": func(input string) string {
 		return strings.Replace(input, ".", "", -1)
 	},
+	"UpperFirst": func(input string) string {
+		if input == "" {
+			return input
+		}
+		return strings.ToUpper(input[:1]) + input[1:]
+	},
+	"GroupGoName": GroupGoName,
+	"Plural":      Pluralize,
+	"Duration":    DurationLiteral,
+}
+
+// DurationLiteral renders a duration as a Go expression, e.g. 90s becomes 90 * time.Second
+func DurationLiteral(d time.Duration) string {
+	for _, unit := range []struct {
+		duration time.Duration
+		name     string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+		{time.Microsecond, "time.Microsecond"},
+	} {
+		if d != 0 && d%unit.duration == 0 {
+			return fmt.Sprintf("%d * %s", d/unit.duration, unit.name)
+		}
+	}
+	return fmt.Sprintf("%d * time.Nanosecond", d)
+}
+
+// GroupGoName derives the Go name of an API group from its first DNS label, e.g.
+// drekle.example.io becomes Drekle. It is set explicitly with +groupGoName so that
+// client-gen produces the same clientset accessor.
+func GroupGoName(group string) string {
+	label := strings.Split(group, ".")[0]
+	var name strings.Builder
+	upper := true
+	for _, r := range label {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			upper = true
+			continue
+		}
+		if upper {
+			name.WriteString(strings.ToUpper(string(r)))
+			upper = false
+			continue
+		}
+		name.WriteRune(r)
+	}
+	return name.String()
 }
 
 var ControllerTemplate = `package controller
@@ -26,78 +138,91 @@ var ControllerTemplate = `package controller
 import (
 	"fmt"
 
+	{{ if .StatusType -}}
+	"k8s.io/apimachinery/pkg/api/equality"
+	{{ end -}}
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	{{- if .Owns }}
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	{{- end }}
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	"time"
 
-	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
-	typedcore{{ .Package }} "k8s.io/client-go/kubernetes/typed/core/{{ .Package }}"
-	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 	pb "{{ .RepoURL }}/pkg/apis/{{ .Group | ToLower }}/{{ .Package }}"
-	informers "{{ .RepoURL }}/pkg/client/informers/externalversions/{{ .Group | ToLower }}/{{ .Package }}"
-	{{ .Name | ToLower}}scheme "{{ .RepoURL }}/pkg/client/clientset/versioned/scheme"
+	"{{ .RepoURL }}/pkg/manager"
+	listers "{{ .RepoURL }}/pkg/client/listers/{{ .Group | ToLower }}/{{ .Package }}"
+	"{{ .RepoURL }}/pkg/server"
 	clientset "{{ .RepoURL }}/pkg/client/clientset/versioned"
 )
 
+// {{ .Name }}Reconciler holds the business logic of the {{ .Name }} controller. It is
+// implemented in {{ .Name }}Reconciler.go, which is only generated when it does not exist.
+type {{ .Name }}Reconciler interface {
+	// Reconcile drives the cluster towards the desired state of the {{ .Name }}.
+	// The argument is a copy which may be modified{{ if .StatusType }}, its status is written back
+	// along with the Ready condition, unless Reconcile sets Ready itself{{ end }}.
+	Reconcile({{ .Name | ToLower }} *pb.{{ .Name }}) error
+	// Purge releases everything held for a deleted {{ .Name }}. It must be idempotent,
+	// the {{ .Name }} is only removed once Purge succeeds.
+	Purge({{ .Name | ToLower }} *pb.{{ .Name }}) error
+}
+
+// {{ .Name | ToLower }}Finalizer keeps deleted {{ .Name }} objects around until Purge succeeds
+const {{ .Name | ToLower }}Finalizer = "{{ .GroupName }}/finalizer"
+
 type {{ .Name | ToLower }}Controller struct {
 	// kubeclientset is a standard kubernetes clientset
 	kubeClientset *kubernetes.Clientset
 	// {{ .Package | ToLower }}Clientset is our generated clientset
 	{{ .Package | ToLower }}Clientset *clientset.Clientset
 
+	reconciler {{ .Name }}Reconciler
+	// workers is the number of goroutines reconciling from updateQueue
+	workers int
+
 	informer cache.SharedIndexInformer
-	// Controller responsible for processing the FIFO queue of SnapshotPolicy objects
-	// and calling provided hook functions
-	controller cache.Controller
+	// lister reads {{ .Name }} objects from the informer cache
+	lister listers.{{ .Name }}Lister
 	// recorder is an event recorder for recording Event resources to the
 	// Kubernetes API.
 	recorder record.EventRecorder
 
+	// The queue holds namespace/name keys, so an object is queued at most once.
+	// Deletions are queued too, they are seen as updates setting the DeletionTimestamp.
 	updateQueue workqueue.RateLimitingInterface
-	deleteQueue workqueue.RateLimitingInterface
 }
 
-func New{{ .Name }}Controller(config *rest.Config) *{{ .Name | ToLower }}Controller {
-
-	utilruntime.Must({{ .Name | ToLower }}scheme.AddToScheme(scheme.Scheme))
-	kubeClientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		klog.Fatalf("Error building kubernetes clientset: %s", err.Error())
-	}
-
-	{{ .Package | ToLower }}Clientset, err := clientset.NewForConfig(config)
-	if err != nil {
-		klog.Fatalf("Error building cxapi clientset: %s", err.Error())
-	}
-
-	klog.V(4).Info("Creating event broadcaster")
-	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartLogging(klog.Infof)
-	eventBroadcaster.StartRecordingToSink(&typedcore{{ .Package }}.EventSinkImpl{Interface: kubeClientset.CoreV1().Events("")})
-	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, core{{ .Package }}.EventSource{Component: "{{ .Name }}-operator"})
-	resyncPeriod := time.Minute * 1
+// New{{ .Name }}Controller watches {{ .Name }} objects through the informers of the manager
+func New{{ .Name }}Controller(mgr *manager.Manager, options {{ .Name }}Opts, reconciler {{ .Name }}Reconciler) *{{ .Name | ToLower }}Controller {
+	resyncPeriod := options.ResyncPeriod
 
 	controller := &{{ .Name | ToLower }}Controller{
-		kubeClientset:  kubeClientset,
-		{{ .Package | ToLower }}Clientset: {{ .Package | ToLower }}Clientset,
-		recorder:       recorder,
+		kubeClientset:  mgr.Kube,
+		{{ .Package | ToLower }}Clientset: mgr.Versioned,
+		recorder:       mgr.Recorder,
+		reconciler:     reconciler,
+		workers:        options.Workers,
 	}
 
-	controller.informer = informers.New{{ .Name }}Informer(
-		{{ .Package | ToLower }}Clientset,
-		corev1.NamespaceAll,
-		resyncPeriod,
-		cache.Indexers{})
+	{{ .Name | ToLower }}Informer := mgr.Informers.{{ .GroupGoName }}().{{ .Package | UpperFirst }}().{{ .Name | Plural }}()
+	controller.informer = {{ .Name | ToLower }}Informer.Informer()
+	controller.lister = {{ .Name | ToLower }}Informer.Lister()
+	// Registered before the manager starts the informers, /readyz fails while the
+	// cache is still syncing
+	server.AddReadyCheck("{{ .Singular }}", controller.informer.HasSynced)
 
 	controller.informer.AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
-		AddFunc: controller.update{{ .Name }},
+		AddFunc: controller.enqueue{{ .Name }},
 		UpdateFunc: func(oldObj, newObj interface{}) {
 			new{{.Name}} := newObj.(*pb.{{ .Name }})
 			old{{.Name}} := oldObj.(*pb.{{ .Name }})
@@ -106,33 +231,50 @@ func New{{ .Name }}Controller(config *rest.Config) *{{ .Name | ToLower }}Control
 				// Two different versions of the same Deployment will always have different RVs.
 				return
 			}
-			controller.update{{ .Name }}(newObj)
+			controller.enqueue{{ .Name }}(newObj)
 		},
-		DeleteFunc: controller.delete{{ .Name }},
 	},
 		resyncPeriod,
 	)
 
-	controller.updateQueue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "{{ .Name }}Update")
-	controller.deleteQueue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "{{ .Name }}Delete")
+	{{- if .Owns }}
+
+	// Changes to owned resources reconcile their controlling {{ .Name }}
+	ownedInformers := []cache.SharedIndexInformer{
+		{{- range $_, $owned := .Owns }}
+		mgr.KubeInformers.{{ $owned.GroupGoName }}().{{ $owned.VersionGoName }}().{{ $owned.Kind | Plural }}().Informer(),
+		{{- end }}
+	}
+	for _, informer := range ownedInformers {
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: controller.enqueueOwner,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				controller.enqueueOwner(newObj)
+			},
+			DeleteFunc: controller.enqueueOwner,
+		})
+	}
+	{{- end }}
+
+	controller.updateQueue = workqueue.NewNamedRateLimitingQueue(options.RateLimiter(), "{{ .Name }}Update")
 
 	return controller
 }
 
 func (c *{{ .Name | ToLower }}Controller) Run(stopCh <-chan struct{}) error {
 	defer utilruntime.HandleCrash()
+	defer c.updateQueue.ShutDown()
 
-	go c.informer.Run(stopCh)
-	if ok := cache.WaitForCacheSync(stopCh, c.informer.HasSynced); !ok {
-		return fmt.Errorf("failed to wait for caches to sync")
+	if c.workers < 1 {
+		return fmt.Errorf("at least one worker is required, got %d", c.workers)
 	}
 
 	klog.Info("Starting {{ .Name }} controller")
 	println("Starting {{ .Name }} controller")
 
-	// any update context will error out if the {{ .RuntimeType }} delete is ran during create
-	go wait.Until(c.runUpdateWorker, time.Second, stopCh)
-	go wait.Until(c.runDeleteWorker, time.Second, stopCh)
+	for i := 0; i < c.workers; i++ {
+		go wait.Until(c.runUpdateWorker, time.Second, stopCh)
+	}
 	<-stopCh
 
 	return nil
@@ -142,33 +284,31 @@ func (c *{{ .Name | ToLower }}Controller) runUpdateWorker() {
 	for c.processNextUpdate() {
 	}
 }
-func (c *{{ .Name | ToLower }}Controller) runDeleteWorker() {
-	for c.processNextDelete() {
-	}
-}
 
-func (c *{{ .Name | ToLower }}Controller) processNextDelete() bool {
-	obj, shutdown := c.deleteQueue.Get()
+func (c *{{ .Name | ToLower }}Controller) processNextUpdate() bool {
+	key, shutdown := c.updateQueue.Get()
 
 	if shutdown {
 		return false
 	}
 
-	println("processing delete")
-
-	//We've ensured that anything added to the queue is of type {{ .RuntimeType }}
-	objImpl := obj.(*pb.{{ .Name }})
+	println("processing update")
 
-	err := func(objImpl *pb.{{ .Name }}) error {
-		defer c.deleteQueue.Done(obj)
+	err := func(key interface{}) error {
+		defer c.updateQueue.Done(key)
 
-		err := c.purge{{ .Name }}(objImpl)
+		// We've ensured that only namespace/name keys are added to the queue
+		k := key.(string)
+		start := time.Now()
+		err := c.sync{{ .Name }}(k)
+		server.ObserveReconcile("{{ .Singular }}", start, err)
 		if err != nil {
-			c.deleteQueue.AddRateLimited(objImpl)
-			return err
+			c.updateQueue.AddRateLimited(key)
+			return fmt.Errorf("error syncing '%s': %s, requeuing", k, err.Error())
 		}
+		c.updateQueue.Forget(key)
 		return nil
-	}(objImpl)
+	}(key)
 
 	if err != nil {
 		utilruntime.HandleError(err)
@@ -178,88 +318,461 @@ func (c *{{ .Name | ToLower }}Controller) processNextDelete() bool {
 	return true
 }
 
-func (c *{{ .Name | ToLower }}Controller) processNextUpdate() bool {
-	obj, shutdown := c.updateQueue.Get()
-
-	if shutdown {
-		return false
+// sync{{ .Name }} reconciles the current state of the {{ .Name }} with the key
+func (c *{{ .Name | ToLower }}Controller) sync{{ .Name }}(key string) error {
+	{{- if .ClusterScoped }}
+	_, name, err := cache.SplitMetaNamespaceKey(key)
+	{{- else }}
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	{{- end }}
+	if err != nil {
+		// A malformed key will never sync, so do not retry it
+		utilruntime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
 	}
 
-	println("processing update")
+	cached, err := c.lister{{ if not .ClusterScoped }}.{{ .Name | Plural }}(namespace){{ end }}.Get(name)
+	if errors.IsNotFound(err) {
+		// Gone since it was queued, it was purged before the finalizer was removed
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-	//We've ensured that anything added to the queue is of type {{ .Name }}
-	objImpl := obj.(*pb.{{ .Name }})
+	// Objects from the informer cache are shared and must not be modified
+	objImpl := cached.DeepCopy()
 
-	err := func(objImpl *pb.{{ .Name }}) error {
-		defer c.updateQueue.Done(obj)
+	if objImpl.DeletionTimestamp != nil {
+		if !containsFinalizer(objImpl.Finalizers, {{ .Name | ToLower }}Finalizer) {
+			// Purged already, or created before the controller managed it
+			return nil
+		}
+		err = c.reconciler.Purge(objImpl)
+		if err != nil {
+			return err
+		}
+		return c.remove{{ .Name }}Finalizer(objImpl)
+	}
 
-		err := c.reconcile{{ .Name }}(objImpl)
+	if !containsFinalizer(objImpl.Finalizers, {{ .Name | ToLower }}Finalizer) {
+		objImpl.Finalizers = append(objImpl.Finalizers, {{ .Name | ToLower }}Finalizer)
+		objImpl, err = c.{{ .Package | ToLower }}Clientset.{{ .GroupGoName }}{{ .Package | UpperFirst }}().{{ .Name | Plural }}({{ if not .ClusterScoped }}namespace{{ end }}).Update(objImpl)
 		if err != nil {
-			c.updateQueue.AddRateLimited(objImpl)
 			return err
 		}
-		return nil
-	}(objImpl)
+	}
 
+	// Fields added since the object was stored, or zero, get the defaults of their annotations
+	scheme.Scheme.Default(objImpl)
+
+	{{- if .StatusType }}
+	ready := objImpl.GetCondition(pb.ConditionReady).DeepCopy()
+	{{- end }}
+	err = c.reconciler.Reconcile(objImpl)
+	{{- if .StatusType }}
 	if err != nil {
-		utilruntime.HandleError(err)
-		return true
+		objImpl.SetCondition(pb.Condition{
+			Type:               pb.ConditionReady,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: objImpl.Generation,
+			Reason:             "ReconcileError",
+			Message:            err.Error(),
+		})
+		if statusErr := c.updateStatus(cached, objImpl); statusErr != nil {
+			utilruntime.HandleError(statusErr)
+		}
+		return err
+	}
+	// The reconciler may set the Ready condition itself, e.g. while still progressing
+	if equality.Semantic.DeepEqual(ready, objImpl.GetCondition(pb.ConditionReady)) {
+		objImpl.SetCondition(pb.Condition{
+			Type:               pb.ConditionReady,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: objImpl.Generation,
+			Reason:             "Reconciled",
+		})
 	}
+	objImpl.Status.ObservedGeneration = objImpl.Generation
+	// Write back the status set while reconciling
+	return c.updateStatus(cached, objImpl)
+	{{- else }}
+	if err != nil {
+		return err
+	}
+	return nil
+	{{- end }}
+}
 
-	return true
+func (c *{{ .Name | ToLower }}Controller) enqueue{{ .Name }}(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.updateQueue.Add(key)
+}
+{{- if .Owns }}
+
+// enqueueOwner queues the {{ .Name }} controlling an owned resource
+func (c *{{ .Name | ToLower }}Controller) enqueueOwner(obj interface{}) {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("error decoding object, invalid type %T", obj))
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("error decoding object tombstone, invalid type %T", tombstone.Obj))
+			return
+		}
+	}
+	owner := metav1.GetControllerOf(object)
+	if owner == nil || owner.Kind != "{{ .Name }}" {
+		return
+	}
+	// Any served version of the {{ .Name }} may have been used for the reference
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil || gv.Group != pb.SchemeGroupVersion.Group {
+		return
+	}
+	{{- if .ClusterScoped }}
+	c.updateQueue.Add(owner.Name)
+	{{- else }}
+	// Owner references never cross namespaces
+	c.updateQueue.Add(object.GetNamespace() + "/" + owner.Name)
+	{{- end }}
+}
+{{- end }}
+
+// remove{{ .Name }}Finalizer lets the API server delete a purged {{ .Name }}. The cached
+// copy may be stale, so the latest version is read on every attempt.
+func (c *{{ .Name | ToLower }}Controller) remove{{ .Name }}Finalizer({{ .Name | ToLower }} *pb.{{ .Name }}) error {
+	client := c.{{ .Package | ToLower }}Clientset.{{ .GroupGoName }}{{ .Package | UpperFirst }}().{{ .Name | Plural }}({{ if not .ClusterScoped }}{{ .Name | ToLower }}.Namespace{{ end }})
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := client.Get({{ .Name | ToLower }}.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !containsFinalizer(latest.Finalizers, {{ .Name | ToLower }}Finalizer) {
+			return nil
+		}
+		latest.Finalizers = removeFinalizer(latest.Finalizers, {{ .Name | ToLower }}Finalizer)
+		_, err = client.Update(latest)
+		return err
+	})
 }
 
-func (c *{{ .Name | ToLower }}Controller) update{{ .Name }}(newObj interface{}) {
-	if ig, ok := newObj.(*pb.{{ .Name }}); ok {
-		c.updateQueue.Add(ig)
+{{- if .StatusType }}
+// updateStatus persists the {{ .Name }} status through the status subresource, unless
+// it is unchanged from the cached {{ .Name }}
+func (c *{{ .Name | ToLower }}Controller) updateStatus(cached *pb.{{ .Name }}, {{ .Name | ToLower }} *pb.{{ .Name }}) error {
+	if equality.Semantic.DeepEqual(cached.Status, {{ .Name | ToLower }}.Status) {
+		return nil
 	}
+	_, err := c.{{ .Package | ToLower }}Clientset.{{ .GroupGoName }}{{ .Package | UpperFirst }}().{{ .Name | Plural }}({{ if not .ClusterScoped }}{{ .Name | ToLower }}.Namespace{{ end }}).UpdateStatus({{ .Name | ToLower }})
+	return err
 }
+{{- end }}
+`
+
+var ControllerEntrypoint = `package controller
+
+import (
+	"time"
 
-func (c *{{ .Name | ToLower }}Controller) delete{{ .Name }}(obj interface{}) {
-	if ig, ok := obj.(*pb.{{ .Name }}); ok {
-		c.deleteQueue.Add(ig)
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+
+	"{{ .RepoURL }}/pkg/manager"
+)
+
+// {{ .Name }}Opts tune the {{ .Name }} controller
+type {{ .Name }}Opts struct {
+	// Workers is the number of {{ .Name }} objects reconciled concurrently
+	Workers int
+	// ResyncPeriod is how often every {{ .Name }} is reconciled again without changes, 0 disables it
+	ResyncPeriod time.Duration
+	// RateLimitBaseDelay and RateLimitMaxDelay bound the exponential backoff of a failing {{ .Name }}
+	RateLimitBaseDelay time.Duration
+	RateLimitMaxDelay  time.Duration
+	// RateLimitQPS and RateLimitBurst bound the overall rate of retries
+	RateLimitQPS   float64
+	RateLimitBurst int
+}
+
+// Default{{ .Name }}Opts are set with +drekle:k8s: annotations on the {{ .Name }} message
+func Default{{ .Name }}Opts() {{ .Name }}Opts {
+	return {{ .Name }}Opts{
+		Workers:            {{ .Defaults.Workers }},
+		ResyncPeriod:       {{ .Defaults.ResyncPeriod | Duration }},
+		RateLimitBaseDelay: {{ .Defaults.RateLimitBaseDelay | Duration }},
+		RateLimitMaxDelay:  {{ .Defaults.RateLimitMaxDelay | Duration }},
+		RateLimitQPS:       {{ .Defaults.RateLimitQPS }},
+		RateLimitBurst:     {{ .Defaults.RateLimitBurst }},
 	}
 }
 
-func (c *{{ .Name | ToLower }}Controller) reconcile{{ .Name }}({{ .Name | ToLower }} *pb.{{ .Name }}) error {
-	//TODO: Implement
-	return fmt.Errorf("reconcile{{ .Name }} not implemented!")
+// RateLimiter retries a failing {{ .Name }} with exponential backoff, within an overall token bucket
+func (o {{ .Name }}Opts) RateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(o.RateLimitBaseDelay, o.RateLimitMaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(o.RateLimitQPS), o.RateLimitBurst)},
+	)
+}
+
+// Add{{ .Name }}Controller registers the {{ .Name }} controller with the manager
+func Add{{ .Name }}Controller(mgr *manager.Manager, opts *Opts) {
+	reconciler := New{{ .Name }}Reconciler(mgr.Clients)
+	mgr.Register("{{ .Singular }}", New{{ .Name }}Controller(mgr, opts.{{ .Name }}, reconciler))
 }
+`
+
+// ReconcilerTemplate scaffolds the user owned Reconciler of a kind. It is never
+// regenerated once it exists.
+var ReconcilerTemplate = `package controller
+
+import (
+	"fmt"
 
-func (c *{{ .Name | ToLower }}Controller) purge{{ .Name }}({{ .Name | ToLower }} *pb.{{ .Name }}) error {
+	pb "{{ .RepoURL }}/pkg/apis/{{ .Group | ToLower }}/{{ .Package }}"
+)
+
+// {{ .Name | ToLower }}Reconciler implements {{ .Name }}Reconciler. This file was generated
+// once and is yours to edit, it is not overwritten when the protos change.
+type {{ .Name | ToLower }}Reconciler struct {
+	clients *Clients
+}
+
+// New{{ .Name }}Reconciler is called once when the {{ .Name }} controller starts
+func New{{ .Name }}Reconciler(clients *Clients) {{ .Name }}Reconciler {
+	return &{{ .Name | ToLower }}Reconciler{clients: clients}
+}
+
+func (r *{{ .Name | ToLower }}Reconciler) Reconcile({{ .Name | ToLower }} *pb.{{ .Name }}) error {
 	//TODO: Implement
-	return fmt.Errorf("delete{{ .Name }} not implemented!")
+	return fmt.Errorf("reconcile {{ .Name }} not implemented!")
+}
+
+func (r *{{ .Name | ToLower }}Reconciler) Purge({{ .Name | ToLower }} *pb.{{ .Name }}) error {
+	//TODO: Release what the {{ .Name }} holds outside the cluster. Returning an
+	// error keeps the {{ .Name }} and its finalizer until Purge succeeds.
+	return nil
 }
 `
 
-var ControllerEntrypoint = `package controller
+// ControllerFinalizerTemplate holds the finalizer helpers shared by every controller
+var ControllerFinalizerTemplate = `package controller
+
+func containsFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	ret := make([]string, 0, len(finalizers))
+	for _, f := range finalizers {
+		if f != finalizer {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+`
+
+// ControllerRunTemplate starts any set of controllers in one process
+var ControllerRunTemplate = `package controller
 
 import (
-	"{{ .RepoURL }}/pkg/signals"
+	"context"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/klog"
+
+	"{{ .RepoURL }}/pkg/manager"
+	"{{ .RepoURL }}/pkg/server"
+	"{{ .RepoURL }}/pkg/signals"
 )
 
-type {{.Name}}Opts struct {
+// Opts are shared by every controller running in the process
+type Opts struct {
 	MasterURL  string
 	Kubeconfig string
+	// Namespace restricts namespaced controllers to a single namespace, all namespaces are watched when empty
+	Namespace string
+
+	// LeaderElect runs the controllers in a single replica at a time, elected through a Lease
+	LeaderElect bool
+	// LeaseNamespace and LeaseName locate the Lease object
+	LeaseNamespace string
+	LeaseName      string
+	// LeaseDuration, RenewDeadline and RetryPeriod tune the election, see leaderelection.LeaderElectionConfig
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+	// Identity of this replica in the Lease, defaults to the hostname
+	Identity string
+
+	// MetricsAddr serves /metrics and HealthAddr /healthz and /readyz, empty disables them
+	MetricsAddr string
+	HealthAddr  string
+	{{ range $_, $controller := .Controllers }}
+	{{ $controller.Name }} {{ $controller.Name }}Opts
+	{{- end }}
 }
 
-func (opts *{{.Name}}Opts) Run() {
+// NewOpts returns the Opts with the defaults of every controller
+func NewOpts() *Opts {
+	return &Opts{
+		{{- range $_, $controller := .Controllers }}
+		{{ $controller.Name }}: Default{{ $controller.Name }}Opts(),
+		{{- end }}
+	}
+}
+
+// Clients are built once by the manager, the alias keeps reconcilers generated
+// before the manager existed compiling
+type Clients = manager.Clients
+
+// Controllers maps the name of each controller to the function registering it
+var Controllers = map[string]func(mgr *manager.Manager, opts *Opts){
+	{{- range $_, $controller := .Controllers }}
+	"{{ $controller.Singular }}": Add{{ $controller.Name }}Controller,
+	{{- end }}
+}
+
+// ControllerNames lists every controller in declaration order
+var ControllerNames = []string{
+	{{- range $_, $controller := .Controllers }}
+	"{{ $controller.Singular }}",
+	{{- end }}
+}
+
+// resyncPeriod is the shortest resync period of the named controllers
+func (opts *Opts) resyncPeriod(names []string) time.Duration {
+	var shortest time.Duration
+	for _, name := range names {
+		var period time.Duration
+		switch name {
+		{{- range $_, $controller := .Controllers }}
+		case "{{ $controller.Singular }}":
+			period = opts.{{ $controller.Name }}.ResyncPeriod
+		{{- end }}
+		}
+		if period > 0 && (shortest == 0 || period < shortest) {
+			shortest = period
+		}
+	}
+	return shortest
+}
+
+// Run starts the named controllers in one process. They share signal handling,
+// clients and informers, and Run returns once every controller has stopped.
+func (opts *Opts) Run(names ...string) {
 
 	// set up signals so we handle the first shutdown signal gracefully
 	stopCh := signals.SetupSignalHandler()
 
+	// Replicas waiting for the leader election are alive and ready too
+	server.Serve(opts.MetricsAddr, opts.HealthAddr)
+
 	cfg, err := clientcmd.BuildConfigFromFlags(opts.MasterURL, opts.Kubeconfig)
 	if err != nil {
 		klog.Fatalf("Error building kubeconfig: %s", err.Error())
 	}
+	for _, name := range names {
+		if _, ok := Controllers[name]; !ok {
+			klog.Fatalf("Unknown controller %s", name)
+		}
+	}
+	mgr, err := manager.New(cfg, opts.Namespace, opts.resyncPeriod(names))
+	if err != nil {
+		klog.Fatalf("Error building the manager: %s", err.Error())
+	}
+
+	if !opts.LeaderElect {
+		opts.runControllers(mgr, names, stopCh)
+		return
+	}
+	opts.runLeaderElection(mgr, names, stopCh)
+}
+
+func (opts *Opts) runControllers(mgr *manager.Manager, names []string, stopCh <-chan struct{}) {
+	for _, name := range names {
+		Controllers[name](mgr, opts)
+	}
+	if err := mgr.Start(stopCh); err != nil {
+		klog.Fatalf("Error running controllers: %s", err.Error())
+	}
+}
 
-	{{ .Name | ToLower }}Controller := New{{ .Name }}Controller(cfg)
+// runLeaderElection only runs the controllers while this replica holds the Lease
+func (opts *Opts) runLeaderElection(mgr *manager.Manager, names []string, stopCh <-chan struct{}) {
+	identity := opts.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			klog.Fatalf("Error getting hostname for the leader election identity: %s", err.Error())
+		}
+		identity = hostname
+	}
 
-	if err = {{ .Name | ToLower }}Controller.Run(stopCh); err != nil {
-		klog.Fatalf("Error running controller: %s", err.Error())
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Namespace: opts.LeaseNamespace,
+			Name:      opts.LeaseName,
+		},
+		Client: mgr.Kube.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: opts.LeaseDuration,
+		RenewDeadline: opts.RenewDeadline,
+		RetryPeriod:   opts.RetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("%s became the leader", identity)
+				opts.runControllers(mgr, names, ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() != nil {
+					// Shutting down
+					return
+				}
+				// Another replica may already be reconciling, so stop at once
+				klog.Fatalf("%s lost the leader election", identity)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					klog.Infof("%s is the leader", leader)
+				}
+			},
+		},
+	})
 }
 `
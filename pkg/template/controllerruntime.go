@@ -0,0 +1,371 @@
+package template
+
+// CR_CONTROLLER_TEMPLATE is the controller-runtime counterpart of ControllerTemplate
+var CR_CONTROLLER_TEMPLATE = `package controller
+
+import (
+	"context"
+	"time"
+
+	{{ if .StatusType -}}
+	"k8s.io/apimachinery/pkg/api/equality"
+	{{ end -}}
+	"k8s.io/apimachinery/pkg/api/errors"
+	{{- if .StatusType }}
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	{{- end }}
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	{{- range $_, $owned := .Owns }}
+	{{ $owned.GroupGoName | ToLower }}{{ $owned.Version }} "k8s.io/api/{{ $owned.GroupGoName | ToLower }}/{{ $owned.Version }}"
+	{{- end }}
+
+	pb "{{ .RepoURL }}/pkg/apis/{{ .Group | ToLower }}/{{ .Package }}"
+)
+
+// {{ .Name }}Handler holds the business logic of the {{ .Name }} controller. It is
+// implemented in {{ .Name }}Handler.go, which is only generated when it does not exist.
+type {{ .Name }}Handler interface {
+	// Reconcile drives the cluster towards the desired state of the {{ .Name }}.
+	// The argument is a copy which may be modified{{ if .StatusType }}, its status is written back
+	// along with the Ready condition, unless Reconcile sets Ready itself{{ end }}.
+	Reconcile(ctx context.Context, {{ .Name | ToLower }} *pb.{{ .Name }}) error
+	// Purge releases everything held for a deleted {{ .Name }}. It must be idempotent,
+	// the {{ .Name }} is only removed once Purge succeeds.
+	Purge(ctx context.Context, {{ .Name | ToLower }} *pb.{{ .Name }}) error
+}
+
+// {{ .Name | ToLower }}Finalizer keeps deleted {{ .Name }} objects around until Purge succeeds
+const {{ .Name | ToLower }}Finalizer = "{{ .GroupName }}/finalizer"
+
+// {{ .Name }}Opts tune the {{ .Name }} controller. The rate limiter annotations have no
+// effect, the controller-runtime version used sets no per controller rate limiter.
+type {{ .Name }}Opts struct {
+	// Workers is the number of {{ .Name }} objects reconciled concurrently
+	Workers int
+	// ResyncPeriod is how often every {{ .Name }} is reconciled again without changes, 0 disables it
+	ResyncPeriod time.Duration
+}
+
+// Default{{ .Name }}Opts are set with +drekle:k8s: annotations on the {{ .Name }} message
+func Default{{ .Name }}Opts() {{ .Name }}Opts {
+	return {{ .Name }}Opts{
+		Workers:      {{ .Defaults.Workers }},
+		ResyncPeriod: {{ .Defaults.ResyncPeriod | Duration }},
+	}
+}
+
+// {{ .Name }}Reconciler reconciles {{ .Name }} objects
+type {{ .Name }}Reconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Handler  {{ .Name }}Handler
+	Options  {{ .Name }}Opts
+}
+
+// +kubebuilder:rbac:groups={{ .GroupName }},resources={{ .Plural }},verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups={{ .GroupName }},resources={{ .Plural }}/finalizers,verbs=update
+{{- if .StatusType }}
+// +kubebuilder:rbac:groups={{ .GroupName }},resources={{ .Plural }}/status,verbs=get;update;patch
+{{- end }}
+{{- range $_, $owned := .Owns }}
+// +kubebuilder:rbac:groups="{{ $owned.Group }}",resources={{ $owned.Resource }},verbs=get;list;watch;create;update;patch;delete
+{{- end }}
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *{{ .Name }}Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+
+	cached := &pb.{{ .Name }}{}
+	err := r.Get(ctx, req.NamespacedName, cached)
+	if errors.IsNotFound(err) {
+		// Gone since it was queued, it was purged before the finalizer was removed
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Objects from the cache are shared and must not be modified
+	objImpl := cached.DeepCopy()
+
+	if objImpl.DeletionTimestamp != nil {
+		if !containsFinalizer(objImpl.Finalizers, {{ .Name | ToLower }}Finalizer) {
+			// Purged already, or created before the controller managed it
+			return ctrl.Result{}, nil
+		}
+		err = r.Handler.Purge(ctx, objImpl)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.removeFinalizer(ctx, objImpl)
+	}
+
+	if !containsFinalizer(objImpl.Finalizers, {{ .Name | ToLower }}Finalizer) {
+		objImpl.Finalizers = append(objImpl.Finalizers, {{ .Name | ToLower }}Finalizer)
+		err = r.Update(ctx, objImpl)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Fields added since the object was stored, or zero, get the defaults of their annotations
+	r.Scheme.Default(objImpl)
+
+	{{- if .StatusType }}
+	ready := objImpl.GetCondition(pb.ConditionReady).DeepCopy()
+	{{- end }}
+	err = r.Handler.Reconcile(ctx, objImpl)
+	{{- if .StatusType }}
+	if err != nil {
+		objImpl.SetCondition(pb.Condition{
+			Type:               pb.ConditionReady,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: objImpl.Generation,
+			Reason:             "ReconcileError",
+			Message:            err.Error(),
+		})
+		if statusErr := r.updateStatus(ctx, cached, objImpl); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{}, err
+	}
+	// The handler may set the Ready condition itself, e.g. while still progressing
+	if equality.Semantic.DeepEqual(ready, objImpl.GetCondition(pb.ConditionReady)) {
+		objImpl.SetCondition(pb.Condition{
+			Type:               pb.ConditionReady,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: objImpl.Generation,
+			Reason:             "Reconciled",
+		})
+	}
+	objImpl.Status.ObservedGeneration = objImpl.Generation
+	// Write back the status set while reconciling
+	err = r.updateStatus(ctx, cached, objImpl)
+	{{- end }}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.Options.ResyncPeriod}, nil
+}
+
+// removeFinalizer lets the API server delete a purged {{ .Name }}. The cached copy
+// may be stale, so the latest version is read on every attempt.
+func (r *{{ .Name }}Reconciler) removeFinalizer(ctx context.Context, {{ .Name | ToLower }} *pb.{{ .Name }}) error {
+	key := client.ObjectKey{Namespace: {{ .Name | ToLower }}.Namespace, Name: {{ .Name | ToLower }}.Name}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &pb.{{ .Name }}{}
+		err := r.Get(ctx, key, latest)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !containsFinalizer(latest.Finalizers, {{ .Name | ToLower }}Finalizer) {
+			return nil
+		}
+		latest.Finalizers = removeFinalizer(latest.Finalizers, {{ .Name | ToLower }}Finalizer)
+		return r.Update(ctx, latest)
+	})
+}
+
+{{- if .StatusType }}
+
+// updateStatus persists the {{ .Name }} status through the status subresource, unless
+// it is unchanged from the cached {{ .Name }}
+func (r *{{ .Name }}Reconciler) updateStatus(ctx context.Context, cached *pb.{{ .Name }}, {{ .Name | ToLower }} *pb.{{ .Name }}) error {
+	if equality.Semantic.DeepEqual(cached.Status, {{ .Name | ToLower }}.Status) {
+		return nil
+	}
+	return r.Status().Update(ctx, {{ .Name | ToLower }})
+}
+{{- end }}
+
+// SetupWithManager watches {{ .Name }} objects{{ if .Owns }} and the resources they control{{ end }}
+func (r *{{ .Name }}Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pb.{{ .Name }}{}).
+		{{- range $_, $owned := .Owns }}
+		Owns(&{{ $owned.GroupGoName | ToLower }}{{ $owned.Version }}.{{ $owned.Kind }}{}).
+		{{- end }}
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Options.Workers}).
+		Complete(r)
+}
+`
+
+// CR_HANDLER_TEMPLATE scaffolds the user owned Handler of a kind. It is never
+// regenerated once it exists.
+var CR_HANDLER_TEMPLATE = `package controller
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pb "{{ .RepoURL }}/pkg/apis/{{ .Group | ToLower }}/{{ .Package }}"
+)
+
+// {{ .Name | ToLower }}Handler implements {{ .Name }}Handler. This file was generated
+// once and is yours to edit, it is not overwritten when the protos change.
+type {{ .Name | ToLower }}Handler struct {
+	client client.Client
+}
+
+// New{{ .Name }}Handler is called once when the {{ .Name }} controller is set up
+func New{{ .Name }}Handler(c client.Client) {{ .Name }}Handler {
+	return &{{ .Name | ToLower }}Handler{client: c}
+}
+
+func (h *{{ .Name | ToLower }}Handler) Reconcile(ctx context.Context, {{ .Name | ToLower }} *pb.{{ .Name }}) error {
+	//TODO: Implement
+	return fmt.Errorf("reconcile {{ .Name }} not implemented!")
+}
+
+func (h *{{ .Name | ToLower }}Handler) Purge(ctx context.Context, {{ .Name | ToLower }} *pb.{{ .Name }}) error {
+	//TODO: Release what the {{ .Name }} holds outside the cluster. Returning an
+	// error keeps the {{ .Name }} and its finalizer until Purge succeeds.
+	return nil
+}
+`
+
+// CR_SETUP_TEMPLATE registers any set of controllers with a controller-runtime manager
+var CR_SETUP_TEMPLATE = `package controller
+
+import (
+	"fmt"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ControllerNames lists every controller in declaration order
+var ControllerNames = []string{
+	{{- range $_, $controller := .Controllers }}
+	"{{ $controller.Singular }}",
+	{{- end }}
+}
+
+// Opts hold the options of every controller
+type Opts struct {
+	{{- range $_, $controller := .Controllers }}
+	{{ $controller.Name }} {{ $controller.Name }}Opts
+	{{- end }}
+}
+
+// NewOpts returns the Opts with the defaults of every controller
+func NewOpts() *Opts {
+	return &Opts{
+		{{- range $_, $controller := .Controllers }}
+		{{ $controller.Name }}: Default{{ $controller.Name }}Opts(),
+		{{- end }}
+	}
+}
+
+// SetupWithManager registers the named controllers with the manager, so that they
+// can be added to an existing manager
+func (opts *Opts) SetupWithManager(mgr ctrl.Manager, names ...string) error {
+	for _, name := range names {
+		var err error
+		switch name {
+		{{- range $_, $controller := .Controllers }}
+		case "{{ $controller.Singular }}":
+			err = (&{{ $controller.Name }}Reconciler{
+				Client:   mgr.GetClient(),
+				Scheme:   mgr.GetScheme(),
+				Recorder: mgr.GetEventRecorderFor("{{ $controller.Singular }}-controller"),
+				Handler:  New{{ $controller.Name }}Handler(mgr.GetClient()),
+				Options:  opts.{{ $controller.Name }},
+			}).SetupWithManager(mgr)
+		{{- end }}
+		default:
+			return fmt.Errorf("unknown controller %s", name)
+		}
+		if err != nil {
+			return fmt.Errorf("error setting up the %s controller: %v", name, err)
+		}
+	}
+	return nil
+}
+`
+
+// CR_MAIN_TEMPLATE runs the controllers in a controller-runtime manager
+var CR_MAIN_TEMPLATE = `package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"{{ .RepoURL }}/pkg/controller"
+	{{- range $_, $pkg := .Packages }}
+	{{ $pkg }} "{{ $.RepoURL }}/pkg/apis/{{ $.Group | ToLower }}/{{ $pkg }}"
+	{{- end }}
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	{{- range $_, $pkg := .Packages }}
+	_ = {{ $pkg }}.AddToScheme(scheme)
+	{{- end }}
+}
+
+func main() {
+	var metricsAddr, namespace, controllers, leaseNamespace, leaseName string
+	var leaderElect bool
+	opts := controller.NewOpts()
+
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address /metrics is served on. \"0\" disables it.")
+	flag.StringVar(&namespace, "namespace", "", "Only watch objects in this namespace. All namespaces are watched when empty.")
+	flag.StringVar(&controllers, "controllers", strings.Join(controller.ControllerNames, ","), "Comma separated controllers to run, of "+strings.Join(controller.ControllerNames, ", ")+".")
+	flag.BoolVar(&leaderElect, "leader-elect", false, "Elect a leader among the replicas so that only one of them runs the controllers.")
+	flag.StringVar(&leaseNamespace, "leader-elect-resource-namespace", "", "Namespace of the leader election lock. Defaults to the namespace the binary runs in.")
+	flag.StringVar(&leaseName, "leader-elect-resource-name", "{{ .Name | ToLower }}-controller", "Name of the leader election lock.")
+	{{- range $_, $controller := .Controllers }}
+	flag.IntVar(&opts.{{ $controller.Name }}.Workers, "{{ $controller.Singular }}-workers", opts.{{ $controller.Name }}.Workers, "Number of {{ $controller.Name }} objects reconciled concurrently.")
+	flag.DurationVar(&opts.{{ $controller.Name }}.ResyncPeriod, "{{ $controller.Singular }}-resync-period", opts.{{ $controller.Name }}.ResyncPeriod, "How often every {{ $controller.Name }} is reconciled again without changes. 0 disables it.")
+	{{- end }}
+	flag.Parse()
+
+	ctrl.SetLogger(zap.Logger(true))
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                  scheme,
+		MetricsBindAddress:      metricsAddr,
+		Namespace:               namespace,
+		LeaderElection:          leaderElect,
+		LeaderElectionNamespace: leaseNamespace,
+		LeaderElectionID:        leaseName,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to create the manager")
+		os.Exit(1)
+	}
+
+	if err = opts.SetupWithManager(mgr, strings.Split(controllers, ",")...); err != nil {
+		setupLog.Error(err, "unable to set up the controllers")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting manager")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
+}
+`
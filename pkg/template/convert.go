@@ -0,0 +1,187 @@
+package template
+
+// ConvertFile holds the converters between the native types of a proto file and
+// the golang/protobuf messages generated into pkg/proto
+type ConvertFile struct {
+	Package string
+	// Source is the proto file the types are generated from
+	Source   string
+	Imports  []NativeImport
+	Enums    []EnumConverter
+	Messages []MessageConverter
+	Kinds    []KindConverter
+}
+
+// EnumConverter converts between a native enum and the proto enum of the same name
+type EnumConverter struct {
+	Name  string
+	Proto string
+}
+
+// MessageConverter converts between a native struct and its proto message
+type MessageConverter struct {
+	Name  string
+	Proto string
+	// ToProto and FromProto are the statements copying the fields of in to out
+	ToProto   []string
+	FromProto []string
+	// ToProtoErr and FromProtoErr are set when the statements assign to a declared err
+	ToProtoErr   bool
+	FromProtoErr bool
+}
+
+// KindConverter converts between a kind and the proto message of its spec
+type KindConverter struct {
+	Name  string
+	Spec  string
+	Proto string
+}
+
+// ConvertTestFile holds the round trip tests of the converters of a proto file
+type ConvertTestFile struct {
+	Package string
+	Source  string
+	Imports []NativeImport
+	Tests   []ConvertTest
+}
+
+// ConvertTest converts Sample, a populated proto message, to the native type named
+// Name, through JSON and back again
+type ConvertTest struct {
+	Name string
+	// Kind is set when Name is a kind rather than a native struct
+	Kind   bool
+	Sample string
+}
+
+// CONVERT_TEMPLATE renders a ConvertFile. nil converts to nil both ways.
+var CONVERT_TEMPLATE = `// Code generated by protoc-gen-k8s from {{ .Source }}. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	{{- range $_, $import := .Imports }}
+	{{ $import.Alias }} "{{ $import.Path }}"
+	{{- end }}
+)
+{{- range $_, $kind := .Kinds }}
+
+// To{{ $kind.Name }}Proto converts the spec of the {{ $kind.Name }} to its proto message
+func To{{ $kind.Name }}Proto(in *{{ $kind.Name }}) (*{{ $kind.Proto }}, error) {
+	if in == nil {
+		return nil, nil
+	}
+	return {{ $kind.Spec }}ToProto(&in.Spec)
+}
+
+// {{ $kind.Name }}FromProto returns a {{ $kind.Name }} with the spec held by the proto message
+func {{ $kind.Name }}FromProto(in *{{ $kind.Proto }}) (*{{ $kind.Name }}, error) {
+	spec, err := {{ $kind.Spec }}FromProto(in)
+	if err != nil || spec == nil {
+		return nil, err
+	}
+	return &{{ $kind.Name }}{Spec: *spec}, nil
+}
+{{- end }}
+{{- range $_, $enum := .Enums }}
+
+// {{ $enum.Name }}ToProto converts a {{ $enum.Name }} to the proto enum, the empty value to zero
+func {{ $enum.Name }}ToProto(in {{ $enum.Name }}) ({{ $enum.Proto }}, error) {
+	if in == "" {
+		return 0, nil
+	}
+	value, ok := {{ $enum.Proto }}_value[string(in)]
+	if !ok {
+		return 0, fmt.Errorf("unknown {{ $enum.Name }} %q", in)
+	}
+	return {{ $enum.Proto }}(value), nil
+}
+
+func {{ $enum.Name }}FromProto(in {{ $enum.Proto }}) ({{ $enum.Name }}, error) {
+	name, ok := {{ $enum.Proto }}_name[int32(in)]
+	if !ok {
+		return "", fmt.Errorf("unknown {{ $enum.Name }} %d", in)
+	}
+	return {{ $enum.Name }}(name), nil
+}
+{{- end }}
+{{- range $_, $message := .Messages }}
+
+// {{ $message.Name }}ToProto converts a {{ $message.Name }} to its proto message. The
+// result shares slices and maps of scalars with in.
+func {{ $message.Name }}ToProto(in *{{ $message.Name }}) (*{{ $message.Proto }}, error) {
+	if in == nil {
+		return nil, nil
+	}
+	{{- if $message.ToProtoErr }}
+	var err error
+	{{- end }}
+	out := &{{ $message.Proto }}{}
+	{{- range $_, $statement := $message.ToProto }}
+	{{ $statement }}
+	{{- end }}
+	return out, nil
+}
+
+// {{ $message.Name }}FromProto converts a proto message to a {{ $message.Name }}. The
+// result shares slices and maps of scalars with in.
+func {{ $message.Name }}FromProto(in *{{ $message.Proto }}) (*{{ $message.Name }}, error) {
+	if in == nil {
+		return nil, nil
+	}
+	{{- if $message.FromProtoErr }}
+	var err error
+	{{- end }}
+	out := &{{ $message.Name }}{}
+	{{- range $_, $statement := $message.FromProto }}
+	{{ $statement }}
+	{{- end }}
+	return out, nil
+}
+{{- end }}
+`
+
+// CONVERT_TEST_TEMPLATE renders a ConvertTestFile
+var CONVERT_TEST_TEMPLATE = `// Code generated by protoc-gen-k8s from {{ .Source }}. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	{{- range $_, $import := .Imports }}
+	{{ $import.Alias }} "{{ $import.Path }}"
+	{{- end }}
+)
+{{- range $_, $test := .Tests }}
+
+func Test{{ $test.Name }}RoundTrip(t *testing.T) {
+	in := {{ $test.Sample }}
+	native, err := {{ $test.Name }}FromProto(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &{{ $test.Name }}{}
+	if err := json.Unmarshal(raw, decoded); err != nil {
+		t.Fatal(err)
+	}
+	{{- if $test.Kind }}
+	out, err := To{{ $test.Name }}Proto(decoded)
+	{{- else }}
+	out, err := {{ $test.Name }}ToProto(decoded)
+	{{- end }}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(in, out) {
+		t.Errorf("round trip of %v through %s returned %v", in, raw, out)
+	}
+}
+{{- end }}
+`
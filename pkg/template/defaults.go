@@ -0,0 +1,66 @@
+package template
+
+// DefaultsFile holds the defaulting functions of the messages of a proto file whose
+// fields, or the fields of messages they hold, carry default annotations
+type DefaultsFile struct {
+	Package string
+	// Source is the proto file the functions are generated from
+	Source   string
+	Imports  []NativeImport
+	Kinds    []KindDefaults
+	Messages []MessageDefaults
+}
+
+// KindDefaults defaults the spec of a kind. Spec is empty when nothing of the spec
+// has a default.
+type KindDefaults struct {
+	Name string
+	Spec string
+}
+
+// MessageDefaults defaults the fields of the Go type Name
+type MessageDefaults struct {
+	Name string
+	// Statements set the unset fields of in to their default
+	Statements []string
+}
+
+// DEFAULTS_TEMPLATE renders a DefaultsFile. The SetDefaults functions of the kinds
+// are registered with the scheme in REGISTER_TYPES_TEMPLATE.
+var DEFAULTS_TEMPLATE = `// Code generated by protoc-gen-k8s from {{ .Source }}. DO NOT EDIT.
+
+package {{ .Package }}
+{{- if .Imports }}
+
+import (
+	{{- range $_, $import := .Imports }}
+	{{ $import.Alias }} "{{ $import.Path }}"
+	{{- end }}
+)
+{{- end }}
+{{- range $_, $kind := .Kinds }}
+
+// SetDefaults_{{ $kind.Name }} sets the defaults of the spec of the {{ $kind.Name }}
+func SetDefaults_{{ $kind.Name }}(in *{{ $kind.Name }}) {
+	{{- if $kind.Spec }}
+	SetDefaults_{{ $kind.Spec }}(&in.Spec)
+	{{- end }}
+}
+
+// SetDefaults_{{ $kind.Name }}List sets the defaults of every item of the list
+func SetDefaults_{{ $kind.Name }}List(in *{{ $kind.Name }}List) {
+	for i := range in.Items {
+		SetDefaults_{{ $kind.Name }}(&in.Items[i])
+	}
+}
+{{- end }}
+{{- range $_, $message := .Messages }}
+
+// SetDefaults_{{ $message.Name }} sets the unset fields of the {{ $message.Name }} to their default
+func SetDefaults_{{ $message.Name }}(in *{{ $message.Name }}) {
+	{{- range $_, $statement := $message.Statements }}
+	{{ $statement }}
+	{{- end }}
+}
+{{- end }}
+`
@@ -29,12 +29,22 @@ CODEGEN_PKG="${SCRIPT_ROOT}/vendor/k8s.io/code-generator"
 # --output-base    because this script should also be able to run inside the vendor dir of
 #                  k8s.io/kubernetes. The output-base is needed for the generators to output into the vendor dir
 #                  instead of the $GOPATH directly. For normal projects this can be dropped.
-bash ${CODEGEN_PKG}/generate-groups.sh "deepcopy,client,informer,lister" \
+bash ${CODEGEN_PKG}/generate-groups.sh {{ if .ControllerRuntime }}"deepcopy"{{ else }}"deepcopy,client,informer,lister"{{ end }} \
   $MODULE/pkg/client \
   $MODULE/pkg/apis \
   {{ .Group | ToLower }}:{{ .Package }} \
   --output-base $ROOT_PACKAGE \
   --go-header-file $SCRIPT_ROOT/hack/boilerplate.go.txt
+{{- if .NativePackages }}
+
+# The packages imported by the kinds only hold plain types
+bash ${CODEGEN_PKG}/generate-groups.sh "deepcopy" \
+  $MODULE/pkg/client \
+  $MODULE/pkg/apis \
+  {{ .Group | ToLower }}:{{ .NativePackages }} \
+  --output-base $ROOT_PACKAGE \
+  --go-header-file $SCRIPT_ROOT/hack/boilerplate.go.txt
+{{- end }}
 
 # This generates the package structure under hack with the correct imports
 rsync -av --stats {{ .RepoURL }}/pkg ..
@@ -9,20 +9,77 @@ const (
 var DOC_TEMPLATE = `// +k8s:deepcopy-gen=package
 
 // +groupName={{ .Group }}
+// +groupGoName={{ .Group | GroupGoName }}
 package {{ .Package }}`
 
 var DREKLE_NAME_ANNOTATION_KEY string = "+drekle:k8s:name="
 var DREKLE_STATUS_TYPE_KEY string = "+drekle:k8s:status="
+var DREKLE_SCOPE_KEY string = "+drekle:k8s:scope="
+var DREKLE_PLURAL_KEY string = "+drekle:k8s:plural="
+var DREKLE_SINGULAR_KEY string = "+drekle:k8s:singular="
+var DREKLE_SHORT_NAMES_KEY string = "+drekle:k8s:shortNames="
+var DREKLE_CATEGORIES_KEY string = "+drekle:k8s:categories="
+
+// DREKLE_OWNS_KEY lists the built-in kinds created for each object, e.g. apps/v1.Deployment,v1.Service
+var DREKLE_OWNS_KEY string = "+drekle:k8s:owns="
+
+// Controller defaults of a kind, overridden with the flags of the generated binary
+var DREKLE_WORKERS_KEY string = "+drekle:k8s:workers="
+var DREKLE_RESYNC_PERIOD_KEY string = "+drekle:k8s:resyncPeriod="
+var DREKLE_RATE_LIMIT_BASE_DELAY_KEY string = "+drekle:k8s:rateLimitBaseDelay="
+var DREKLE_RATE_LIMIT_MAX_DELAY_KEY string = "+drekle:k8s:rateLimitMaxDelay="
+var DREKLE_RATE_LIMIT_QPS_KEY string = "+drekle:k8s:rateLimitQPS="
+var DREKLE_RATE_LIMIT_BURST_KEY string = "+drekle:k8s:rateLimitBurst="
+
+// Validation annotations of fields, turned into CRD schema constraints and the
+// generated Validate functions. Value constraints of repeated fields and maps apply
+// to each value.
+var DREKLE_MINIMUM_KEY string = "+drekle:k8s:minimum="
+var DREKLE_MAXIMUM_KEY string = "+drekle:k8s:maximum="
+var DREKLE_PATTERN_KEY string = "+drekle:k8s:pattern="
+var DREKLE_MIN_LENGTH_KEY string = "+drekle:k8s:minLength="
+var DREKLE_MAX_LENGTH_KEY string = "+drekle:k8s:maxLength="
+var DREKLE_MIN_ITEMS_KEY string = "+drekle:k8s:minItems="
+var DREKLE_MAX_ITEMS_KEY string = "+drekle:k8s:maxItems="
+var DREKLE_ENUM_KEY string = "+drekle:k8s:enum="
+var DREKLE_REQUIRED_MARKER string = "+drekle:k8s:required"
+
+// DREKLE_DEFAULT_KEY sets the default of a scalar or enum field, both in the CRD
+// schema and in the generated SetDefaults functions
+var DREKLE_DEFAULT_KEY string = "+drekle:k8s:default="
+
+// RESOURCE_NAME_MARKER overrides the resource client-gen derives from the kind
+var RESOURCE_NAME_MARKER string = "+resourceName="
+
+// NON_NAMESPACED_MARKER is the client-gen marker for cluster scoped kinds
+var NON_NAMESPACED_MARKER string = "+genclient:nonNamespaced"
+
+const (
+	SCOPE_NAMESPACED = "Namespaced"
+	SCOPE_CLUSTER    = "Cluster"
+)
 
 type ProtoMessage struct {
 	Package string
 	RepoURL string
 	Group   string
 	// using the +drekle:k8s:name annotation
-	Name            string
-	RuntimeType     string
-	StatusType      string
+	Name        string
+	RuntimeType string
+	StatusType  string
+	// Scope is either Namespaced or Cluster
+	Scope string
+	// Plural and Singular are the lower case resource names
+	Plural          string
+	Singular        string
+	ShortNames      []string
+	Categories      []string
 	LeadingComments []string
+	// ControllerRuntime is set when the project is generated for controller-runtime
+	ControllerRuntime bool
+	// NativePackages are the comma separated packages of native types imported by
+	// the kinds, which only need deepcopy functions
+	NativePackages string
 }
 
 type ProtoFile struct {
@@ -30,34 +87,101 @@ type ProtoFile struct {
 	Group    string
 	RepoURL  string
 	Messages []*ProtoMessage
+	// ControllerRuntime adds the kubebuilder markers controller-gen reads
+	ControllerRuntime bool
 }
 
 var K8S_TYPE_TEMPLATE = `package {{ .Package }}
 
 import (
+	"fmt"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
 {{ range $_, $value := .Messages }}
-	{{ $value.Name }}Resource = "{{ $value.Name | ToLower }}"
-	{{ $value.Name }}ResourcePlural = "{{ $value.Name | ToLower }}s"{{ end }}
+	{{ $value.Name }}Resource = "{{ $value.Singular }}"
+	{{ $value.Name }}ResourcePlural = "{{ $value.Plural }}"{{ end }}
 )
 
 {{ range $_, $value := .Messages }}
 {{ range $_, $comment := $value.LeadingComments }}
 // {{ $comment }}{{ end }}
+{{- if $.ControllerRuntime }}
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path={{ $value.Plural }},singular={{ $value.Singular }},scope={{ $value.Scope }}
+{{- if $value.ShortNames }},shortName={{ range $i, $name := $value.ShortNames }}{{ if $i }};{{ end }}{{ $name }}{{ end }}{{ end }}
+{{- if $value.Categories }},categories={{ range $i, $name := $value.Categories }}{{ if $i }};{{ end }}{{ $name }}{{ end }}{{ end }}
+{{- if $value.StatusType }}
+// +kubebuilder:subresource:status
+{{- end }}
+{{- end }}
 type {{ $value.Name }} struct {
 	metav1.TypeMeta   ` + "`json:\",inline\"`" + `
 	metav1.ObjectMeta ` + "`json:\"metadata,omitempty\"`" + `
 
 	Spec   {{ $value.RuntimeType }} ` + "`json:\"spec\"`" + `
 	{{ if $value.StatusType }}
-	Status {{ $value.StatusType }}  ` + "`json:\"status\"`" + `
+	Status {{ $value.Name }}ResourceStatus  ` + "`json:\"status,omitempty\"`" + `
 	{{ end }}
 }
+// ControllerRef returns an owner reference marking the {{ $value.Name }} as the controller of an object
+func (in *{{ $value.Name }}) ControllerRef() *metav1.OwnerReference {
+	return metav1.NewControllerRef(in, SchemeGroupVersion.WithKind("{{ $value.Name }}"))
+}
+
+// SetControllerOf makes the {{ $value.Name }} the controller of the object, so that the
+// object is garbage collected with it and its changes reconcile the {{ $value.Name }}
+func (in *{{ $value.Name }}) SetControllerOf(object metav1.Object) error {
+	ref := in.ControllerRef()
+	owners := object.GetOwnerReferences()
+	for i := range owners {
+		if owners[i].Controller == nil || !*owners[i].Controller {
+			continue
+		}
+		if owners[i].UID != ref.UID {
+			return fmt.Errorf("%s is already controlled by %s %s", object.GetName(), owners[i].Kind, owners[i].Name)
+		}
+		owners[i] = *ref
+		object.SetOwnerReferences(owners)
+		return nil
+	}
+	object.SetOwnerReferences(append(owners, *ref))
+	return nil
+}
+{{ if $value.StatusType }}
+// {{ $value.Name }}ResourceStatus adds the conditions and observed generation every
+// status carries to {{ $value.StatusType }}
+type {{ $value.Name }}ResourceStatus struct {
+	{{ $value.StatusType }} ` + "`json:\",inline\"`" + `
+
+	// ObservedGeneration is the metadata.generation the status was last reconciled at
+	ObservedGeneration int64 ` + "`json:\"observedGeneration,omitempty\"`" + `
+	Conditions []Condition ` + "`json:\"conditions,omitempty\"`" + `
+}
+
+// SetCondition adds or replaces the condition of the same type
+func (in *{{ $value.Name }}) SetCondition(condition Condition) {
+	setCondition(&in.Status.Conditions, condition)
+}
+
+// GetCondition returns the condition of the type, or nil
+func (in *{{ $value.Name }}) GetCondition(conditionType string) *Condition {
+	return findCondition(in.Status.Conditions, conditionType)
+}
+
+// IsReady reports whether the Ready condition is True for the current generation
+func (in *{{ $value.Name }}) IsReady() bool {
+	ready := in.GetCondition(ConditionReady)
+	return ready != nil && ready.Status == metav1.ConditionTrue && ready.ObservedGeneration == in.Generation
+}
+{{ end }}
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+{{- if $.ControllerRuntime }}
+// +kubebuilder:object:root=true
+{{- end }}
 type {{ $value.Name }}List struct {
 	metav1.TypeMeta   ` + "`json:\",inline\"`" + `
 	metav1.ObjectMeta ` + "`json:\"metadata,omitempty\"`" + `
@@ -91,9 +215,16 @@ func Resource(resource string) schema.GroupResource {
 	return SchemeGroupVersion.WithResource(resource).GroupResource()
 }
 
+var (
+	{{- range $_, $message := .Messages }}
+	// {{ $message.Name }}GroupResource is the Group qualified resource of {{ $message.Name }}
+	{{ $message.Name }}GroupResource = Resource({{ $message.Name }}ResourcePlural)
+	{{- end }}
+)
+
 var (
 	// Variables referenced in generation
-	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes, addDefaultingFuncs)
 	AddToScheme   = SchemeBuilder.AddToScheme
 )
 
@@ -102,12 +233,79 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		{{ range $_, $message := .Messages }}
 		&{{ $message.Name }}{},
+		&{{ $message.Name }}List{},
 		{{ end }}
 	)
-	{{ range $_, $message := .Messages }}
-	scheme.AddKnownTypeWithName(SchemeGroupVersion.WithKind({{ $message.Name }}ResourcePlural), &{{ $message.Name }}List{})
-	{{ end }}
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
 }
+
+// addDefaultingFuncs registers the generated SetDefaults functions of the kinds, which
+// scheme.Default applies
+func addDefaultingFuncs(scheme *runtime.Scheme) error {
+	{{- range $_, $message := .Messages }}
+	scheme.AddTypeDefaultingFunc(&{{ $message.Name }}{}, func(obj interface{}) { SetDefaults_{{ $message.Name }}(obj.(*{{ $message.Name }})) })
+	scheme.AddTypeDefaultingFunc(&{{ $message.Name }}List{}, func(obj interface{}) { SetDefaults_{{ $message.Name }}List(obj.(*{{ $message.Name }}List)) })
+	{{- end }}
+	return nil
+}
+`
+
+// CONDITIONS_TEMPLATE is generated once per package with a status type
+var CONDITIONS_TEMPLATE = `package {{ .Package }}
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionReady is set by the controller from the result of every reconcile
+const ConditionReady = "Ready"
+
+// Condition is an observation of an aspect of the state of an object
+type Condition struct {
+	// Type of the condition in CamelCase, e.g. Ready
+	Type string ` + "`json:\"type\"`" + `
+	// Status is one of True, False or Unknown
+	Status metav1.ConditionStatus ` + "`json:\"status\"`" + `
+	// ObservedGeneration is the metadata.generation the condition was set at
+	ObservedGeneration int64 ` + "`json:\"observedGeneration,omitempty\"`" + `
+	// LastTransitionTime is when the status last changed
+	LastTransitionTime metav1.Time ` + "`json:\"lastTransitionTime,omitempty\"`" + `
+	// Reason is a CamelCase reason for the last transition
+	Reason string ` + "`json:\"reason,omitempty\"`" + `
+	// Message is a human readable description of the last transition
+	Message string ` + "`json:\"message,omitempty\"`" + `
+}
+
+// setCondition adds or replaces the condition of the same type. The transition
+// time only moves when the status changes.
+func setCondition(conditions *[]Condition, condition Condition) {
+	existing := findCondition(*conditions, condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		*conditions = append(*conditions, condition)
+		return
+	}
+	if existing.Status != condition.Status {
+		existing.Status = condition.Status
+		existing.LastTransitionTime = condition.LastTransitionTime
+		if existing.LastTransitionTime.IsZero() {
+			existing.LastTransitionTime = metav1.Now()
+		}
+	}
+	existing.ObservedGeneration = condition.ObservedGeneration
+	existing.Reason = condition.Reason
+	existing.Message = condition.Message
+}
+
+func findCondition(conditions []Condition, conditionType string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
 `
@@ -0,0 +1,134 @@
+package template
+
+// ManagerOpts names the manager of the generated binary
+type ManagerOpts struct {
+	// Name is the Go name of the API group, used for the event source
+	Name    string
+	RepoURL string
+}
+
+// MANAGER_TEMPLATE shares clients, informers and the event recorder between every
+// controller of the process
+var MANAGER_TEMPLATE = `package manager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+
+	clientset "{{ .RepoURL }}/pkg/client/clientset/versioned"
+	versionedscheme "{{ .RepoURL }}/pkg/client/clientset/versioned/scheme"
+	informers "{{ .RepoURL }}/pkg/client/informers/externalversions"
+)
+
+// Clients are shared by every controller running in the process
+type Clients struct {
+	// Kube is a standard kubernetes clientset
+	Kube *kubernetes.Clientset
+	// Versioned is our generated clientset
+	Versioned *clientset.Clientset
+	// Informers watch our kinds, KubeInformers the built-in resources owned by them.
+	// Both are limited to the namespace of the Manager.
+	Informers     informers.SharedInformerFactory
+	KubeInformers kubeinformers.SharedInformerFactory
+}
+
+// Controller is run by the Manager once every informer cache has synced
+type Controller interface {
+	Run(stopCh <-chan struct{}) error
+}
+
+// Manager builds the clients once and runs every registered controller on them
+type Manager struct {
+	*Clients
+	// Recorder records events on behalf of every controller
+	Recorder record.EventRecorder
+
+	names       []string
+	controllers []Controller
+}
+
+// New creates a Manager watching the namespace, or all namespaces when it is empty.
+// resyncPeriod is the shortest resync period of the controllers, 0 disables resyncs.
+func New(config *rest.Config, namespace string, resyncPeriod time.Duration) (*Manager, error) {
+	utilruntime.Must(versionedscheme.AddToScheme(scheme.Scheme))
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error building kubernetes clientset: %v", err)
+	}
+	versioned, err := clientset.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error building {{ .Name }} clientset: %v", err)
+	}
+
+	klog.V(4).Info("Creating event broadcaster")
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kube.CoreV1().Events("")})
+
+	return &Manager{
+		Clients: &Clients{
+			Kube:          kube,
+			Versioned:     versioned,
+			Informers:     informers.NewSharedInformerFactoryWithOptions(versioned, resyncPeriod, informers.WithNamespace(namespace)),
+			KubeInformers: kubeinformers.NewSharedInformerFactoryWithOptions(kube, 0, kubeinformers.WithNamespace(namespace)),
+		},
+		Recorder: eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "{{ .Name | ToLower }}-operator"}),
+	}, nil
+}
+
+// Register adds a controller. Controllers must request their informers from the
+// factories before Start is called.
+func (m *Manager) Register(name string, controller Controller) {
+	m.names = append(m.names, name)
+	m.controllers = append(m.controllers, controller)
+}
+
+// Start starts the informer factories once, waits for every cache to sync and runs
+// the controllers until stopCh is closed
+func (m *Manager) Start(stopCh <-chan struct{}) error {
+	m.Informers.Start(stopCh)
+	m.KubeInformers.Start(stopCh)
+	for informerType, synced := range m.Informers.WaitForCacheSync(stopCh) {
+		if !synced {
+			return fmt.Errorf("failed to wait for the %v cache to sync", informerType)
+		}
+	}
+	for informerType, synced := range m.KubeInformers.WaitForCacheSync(stopCh) {
+		if !synced {
+			return fmt.Errorf("failed to wait for the %v cache to sync", informerType)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var errs []error
+	for i := range m.controllers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := m.controllers[i].Run(stopCh); err != nil {
+				err = fmt.Errorf("error running %s controller: %v", m.names[i], err)
+				// The other controllers keep running, so report it right away
+				utilruntime.HandleError(err)
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+`
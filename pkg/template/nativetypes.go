@@ -0,0 +1,82 @@
+package template
+
+// NativeFile holds the plain Go types generated for the messages and enums of a
+// proto file when the golang/protobuf messages should not leak into the API
+type NativeFile struct {
+	Package string
+	// Source is the proto file the types are generated from
+	Source  string
+	Imports []NativeImport
+	Enums   []NativeEnum
+	Structs []NativeStruct
+}
+
+type NativeImport struct {
+	Alias string
+	Path  string
+}
+
+// NativeEnum is a proto enum, serialized by the name of its values
+type NativeEnum struct {
+	Name     string
+	Comments []string
+	Values   []NativeEnumValue
+}
+
+type NativeEnumValue struct {
+	// Name is the Go constant, Value the proto name of the value
+	Name     string
+	Value    string
+	Comments []string
+}
+
+// NativeStruct is a proto message
+type NativeStruct struct {
+	Name     string
+	Comments []string
+	Fields   []NativeField
+}
+
+type NativeField struct {
+	Name     string
+	Type     string
+	Tag      string
+	Comments []string
+}
+
+// NATIVE_TYPES_TEMPLATE renders a NativeFile
+var NATIVE_TYPES_TEMPLATE = `// Code generated by protoc-gen-k8s from {{ .Source }}. DO NOT EDIT.
+
+package {{ .Package }}
+{{ if .Imports }}
+import (
+	{{- range $_, $import := .Imports }}
+	{{ $import.Alias }} "{{ $import.Path }}"
+	{{- end }}
+)
+{{ end }}
+{{- range $_, $enum := .Enums }}
+{{ range $_, $comment := $enum.Comments }}
+// {{ $comment }}{{ end }}
+type {{ $enum.Name }} string
+
+const (
+	{{- range $_, $value := $enum.Values }}
+	{{- range $_, $comment := $value.Comments }}
+	// {{ $comment }}{{ end }}
+	{{ $value.Name }} {{ $enum.Name }} = "{{ $value.Value }}"
+	{{- end }}
+)
+{{ end }}
+{{- range $_, $struct := .Structs }}
+{{ range $_, $comment := $struct.Comments }}
+// {{ $comment }}{{ end }}
+// +k8s:deepcopy-gen=true
+type {{ $struct.Name }} struct {
+	{{- range $_, $field := $struct.Fields }}
+	{{- range $_, $comment := $field.Comments }}
+	// {{ $comment }}{{ end }}
+	{{ $field.Name }} {{ $field.Type }} ` + "`{{ $field.Tag }}`" + `
+	{{- end }}
+}
+{{ end }}`
@@ -0,0 +1,49 @@
+package template
+
+import "strings"
+
+// pluralExceptions mirrors the exceptions client-gen passes to the gengo namer
+var pluralExceptions = map[string]string{
+	"Endpoints": "Endpoints",
+}
+
+// Pluralize returns the plural of a kind following the k8s.io/gengo plural namer
+// rules, which client-gen uses to name typed client accessors, e.g. Policy becomes
+// Policies.
+func Pluralize(singular string) string {
+	if plural, ok := pluralExceptions[singular]; ok {
+		return plural
+	}
+	if len(singular) < 2 {
+		return singular
+	}
+	switch singular[len(singular)-1] {
+	case 's', 'x', 'z':
+		return singular + "es"
+	case 'y':
+		if isConsonant(singular[len(singular)-2]) {
+			return singular[:len(singular)-1] + "ies"
+		}
+	case 'h':
+		if last := singular[len(singular)-2]; last == 'c' || last == 's' {
+			return singular + "es"
+		}
+	case 'e':
+		if singular[len(singular)-2] == 'f' {
+			return singular[:len(singular)-2] + "ves"
+		}
+	case 'f':
+		return singular[:len(singular)-1] + "ves"
+	}
+	return singular + "s"
+}
+
+// ResourcePlural is the lower case plural client-gen uses as the REST resource
+func ResourcePlural(kind string) string {
+	return strings.ToLower(Pluralize(kind))
+}
+
+// isConsonant matches gengo, which only takes lower case letters for consonants
+func isConsonant(char byte) bool {
+	return strings.IndexByte("bcdfghjklmnpqrstvwxyz", char) >= 0
+}
@@ -0,0 +1,39 @@
+package template
+
+import "testing"
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		singular string
+		plural   string
+	}{
+		{"Policy", "Policies"},
+		{"Ingress", "Ingresses"},
+		{"Proxy", "Proxies"},
+		{"Endpoints", "Endpoints"},
+		{"Leaf", "Leaves"},
+		{"Knife", "Knives"},
+		{"Box", "Boxes"},
+		{"Quiz", "Quizes"},
+		{"Batch", "Batches"},
+		{"Mesh", "Meshes"},
+		{"Graph", "Graphs"},
+		{"Key", "Keys"},
+		// gengo only takes lower case letters for consonants
+		{"IPy", "IPys"},
+		{"Pod", "Pods"},
+		{"Gadget", "Gadgets"},
+		{"A", "A"},
+	}
+	for _, test := range tests {
+		if plural := Pluralize(test.singular); plural != test.plural {
+			t.Errorf("Pluralize(%q) = %q, want %q", test.singular, plural, test.plural)
+		}
+	}
+}
+
+func TestResourcePlural(t *testing.T) {
+	if plural := ResourcePlural("NetworkPolicy"); plural != "networkpolicies" {
+		t.Errorf("ResourcePlural(%q) = %q, want %q", "NetworkPolicy", plural, "networkpolicies")
+	}
+}
@@ -0,0 +1,100 @@
+package template
+
+type RBACOpts struct {
+	Name string
+	// Group is the full API group name, e.g. drekle.example.io
+	Group         string
+	Plural        string
+	StatusType    string
+	ClusterScoped bool
+	Owns          []OwnedResource
+	// ControllerRuntime projects lock leader election with a ConfigMap
+	ControllerRuntime bool
+}
+
+// RBAC_TEMPLATE grants the controller access to its kind. Cluster scoped kinds need
+// a ClusterRole, namespaced kinds are granted a Role in the watched namespace.
+var RBAC_TEMPLATE = `apiVersion: rbac.authorization.k8s.io/v1
+{{- if .ClusterScoped }}
+kind: ClusterRole
+{{- else }}
+kind: Role
+{{- end }}
+metadata:
+  name: {{ .Name | ToLower }}-controller
+rules:
+- apiGroups:
+  - {{ .Group }}
+  resources:
+  - {{ .Plural }}
+  verbs:
+  - get
+  - list
+  - watch
+  - update
+  - patch
+- apiGroups:
+  - {{ .Group }}
+  resources:
+  - {{ .Plural }}/finalizers
+  verbs:
+  - update
+{{- if .StatusType }}
+- apiGroups:
+  - {{ .Group }}
+  resources:
+  - {{ .Plural }}/status
+  verbs:
+  - get
+  - update
+  - patch
+{{- end }}
+{{- range $_, $owned := .Owns }}
+- apiGroups:
+  - "{{ $owned.Group }}"
+  resources:
+  - {{ $owned.Resource }}
+  verbs:
+  - get
+  - list
+  - watch
+  - create
+  - update
+  - patch
+  - delete
+{{- end }}
+- apiGroups:
+  - ""
+  resources:
+  - events
+  verbs:
+  - create
+  - patch
+`
+
+// LEADER_ELECTION_RBAC_TEMPLATE grants the controllers access to the Lease used
+// for leader election. The Role must be bound in the lease namespace.
+var LEADER_ELECTION_RBAC_TEMPLATE = `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: {{ .Name | ToLower }}-leader-election
+rules:
+- apiGroups:
+  - coordination.k8s.io
+  resources:
+  - leases
+  verbs:
+  - get
+  - create
+  - update
+{{- if .ControllerRuntime }}
+- apiGroups:
+  - ""
+  resources:
+  - configmaps
+  verbs:
+  - get
+  - create
+  - update
+{{- end }}
+`
@@ -0,0 +1,210 @@
+package template
+
+// SERVER_TEMPLATE serves the metrics and health endpoints of the controller binary
+var SERVER_TEMPLATE = `package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+var (
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_reconcile_total",
+		Help: "Total number of reconciles per controller and result",
+	}, []string{"controller", "result"})
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "controller_reconcile_duration_seconds",
+		Help: "How long a reconcile takes per controller",
+	}, []string{"controller"})
+)
+
+func init() {
+	prometheus.MustRegister(reconcileTotal, reconcileDuration)
+	workqueue.SetProvider(newWorkqueueMetricsProvider())
+}
+
+// ObserveReconcile records a reconcile of the controller which started at start
+func ObserveReconcile(controller string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	reconcileTotal.WithLabelValues(controller, result).Inc()
+	reconcileDuration.WithLabelValues(controller).Observe(time.Since(start).Seconds())
+}
+
+var readyChecks = struct {
+	sync.Mutex
+	checks map[string]func() bool
+}{checks: make(map[string]func() bool)}
+
+// AddReadyCheck makes /readyz fail until check passes, e.g. until an informer has synced
+func AddReadyCheck(name string, check func() bool) {
+	readyChecks.Lock()
+	defer readyChecks.Unlock()
+	readyChecks.checks[name] = check
+}
+
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+func readyz(w http.ResponseWriter, r *http.Request) {
+	readyChecks.Lock()
+	defer readyChecks.Unlock()
+	for name, check := range readyChecks.checks {
+		if !check() {
+			http.Error(w, name+" is not ready", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Write([]byte("ok"))
+}
+
+// Serve starts serving /metrics on metricsAddr and /healthz and /readyz on
+// healthAddr in the background. An empty address disables the endpoints, both may
+// share the same address.
+func Serve(metricsAddr string, healthAddr string) {
+	muxes := make(map[string]*http.ServeMux)
+	mux := func(addr string) *http.ServeMux {
+		if _, ok := muxes[addr]; !ok {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if metricsAddr != "" {
+		mux(metricsAddr).Handle("/metrics", promhttp.Handler())
+	}
+	if healthAddr != "" {
+		mux(healthAddr).HandleFunc("/healthz", healthz)
+		mux(healthAddr).HandleFunc("/readyz", readyz)
+	}
+	for addr, handler := range muxes {
+		go func(addr string, handler http.Handler) {
+			klog.Infof("Serving on %s", addr)
+			klog.Fatal(http.ListenAndServe(addr, handler))
+		}(addr, handler)
+	}
+}
+
+// workqueueMetricsProvider exports the metrics of every named workqueue
+type workqueueMetricsProvider struct {
+	depth          *prometheus.GaugeVec
+	adds           *prometheus.CounterVec
+	latency        *prometheus.HistogramVec
+	workDuration   *prometheus.HistogramVec
+	unfinished     *prometheus.GaugeVec
+	longestRunning *prometheus.GaugeVec
+	retries        *prometheus.CounterVec
+}
+
+func newWorkqueueMetricsProvider() *workqueueMetricsProvider {
+	p := &workqueueMetricsProvider{
+		depth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "workqueue_depth",
+			Help: "Current depth of the workqueue",
+		}, []string{"name"}),
+		adds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "workqueue_adds_total",
+			Help: "Total number of adds handled by the workqueue",
+		}, []string{"name"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "workqueue_queue_duration_seconds",
+			Help:    "How long an item stays in the workqueue before being requested",
+			Buckets: prometheus.ExponentialBuckets(10e-9, 10, 10),
+		}, []string{"name"}),
+		workDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "workqueue_work_duration_seconds",
+			Help:    "How long processing an item from the workqueue takes",
+			Buckets: prometheus.ExponentialBuckets(10e-9, 10, 10),
+		}, []string{"name"}),
+		unfinished: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "workqueue_unfinished_work_seconds",
+			Help: "How many seconds of work are in progress and not yet observed by work_duration",
+		}, []string{"name"}),
+		longestRunning: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "workqueue_longest_running_processor_seconds",
+			Help: "How many seconds the longest running processor of the workqueue has been running",
+		}, []string{"name"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "workqueue_retries_total",
+			Help: "Total number of retries handled by the workqueue",
+		}, []string{"name"}),
+	}
+	prometheus.MustRegister(p.depth, p.adds, p.latency, p.workDuration, p.unfinished, p.longestRunning, p.retries)
+	return p
+}
+
+func (p *workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return p.depth.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return p.adds.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return p.latency.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return p.workDuration.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return p.unfinished.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return p.longestRunning.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return p.retries.WithLabelValues(name)
+}
+
+// The deprecated metrics are still part of the provider interface of the pinned
+// client-go, they are not exported.
+
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
+
+func (p *workqueueMetricsProvider) NewDeprecatedDepthMetric(name string) workqueue.GaugeMetric {
+	return noopMetric{}
+}
+
+func (p *workqueueMetricsProvider) NewDeprecatedAddsMetric(name string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+
+func (p *workqueueMetricsProvider) NewDeprecatedLatencyMetric(name string) workqueue.SummaryMetric {
+	return noopMetric{}
+}
+
+func (p *workqueueMetricsProvider) NewDeprecatedWorkDurationMetric(name string) workqueue.SummaryMetric {
+	return noopMetric{}
+}
+
+func (p *workqueueMetricsProvider) NewDeprecatedUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (p *workqueueMetricsProvider) NewDeprecatedLongestRunningProcessorMicrosecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (p *workqueueMetricsProvider) NewDeprecatedRetriesMetric(name string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+`
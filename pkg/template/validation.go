@@ -0,0 +1,79 @@
+package template
+
+// ValidationFile holds the Validate functions of the messages of a proto file whose
+// fields, or the fields of messages they hold, carry validation annotations
+type ValidationFile struct {
+	Package string
+	// Source is the proto file the functions are generated from
+	Source   string
+	Imports  []NativeImport
+	Patterns []ValidationPattern
+	Kinds    []KindValidation
+	Messages []MessageValidation
+}
+
+// ValidationPattern is a compiled pattern annotation
+type ValidationPattern struct {
+	Name string
+	// Expr is the regular expression as a quoted Go string
+	Expr string
+}
+
+// KindValidation validates the spec of a kind. Spec is empty when nothing of the
+// spec is validated.
+type KindValidation struct {
+	Name string
+	Spec string
+}
+
+// MessageValidation validates the fields of the Go type Name
+type MessageValidation struct {
+	Name string
+	// Statements append the errors of each field of in to allErrs
+	Statements []string
+}
+
+// VALIDATION_TEMPLATE renders a ValidationFile
+var VALIDATION_TEMPLATE = `// Code generated by protoc-gen-k8s from {{ .Source }}. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	{{- range $_, $import := .Imports }}
+	{{ $import.Alias }} "{{ $import.Path }}"
+	{{- end }}
+)
+{{- if .Patterns }}
+
+var (
+	{{- range $_, $pattern := .Patterns }}
+	{{ $pattern.Name }} = regexp.MustCompile({{ $pattern.Expr }})
+	{{- end }}
+)
+{{- end }}
+{{- range $_, $kind := .Kinds }}
+
+// Validate checks the spec of the {{ $kind.Name }} against the validation annotations of its fields
+func (in *{{ $kind.Name }}) Validate() field.ErrorList {
+	{{- if $kind.Spec }}
+	return Validate{{ $kind.Spec }}(&in.Spec, field.NewPath("spec"))
+	{{- else }}
+	return field.ErrorList{}
+	{{- end }}
+}
+{{- end }}
+{{- range $_, $message := .Messages }}
+
+// Validate{{ $message.Name }} checks the fields of a {{ $message.Name }} against their validation annotations
+func Validate{{ $message.Name }}(in *{{ $message.Name }}, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if in == nil {
+		return allErrs
+	}
+	{{- range $_, $statement := $message.Statements }}
+	{{ $statement }}
+	{{- end }}
+	return allErrs
+}
+{{- end }}
+`
@@ -0,0 +1,156 @@
+package template
+
+// WrapperType is a google.protobuf wrapper and the Go type of its value
+type WrapperType struct {
+	Name    string
+	GoType  string
+	Pointer bool
+}
+
+// WellKnownOpts lists the messages converted by WELL_KNOWN_CONVERT_TEMPLATE
+type WellKnownOpts struct {
+	Wrappers []WrapperType
+	// JSON are the google.protobuf messages holding arbitrary JSON, e.g. Struct
+	JSON []string
+}
+
+// WELL_KNOWN_CONVERT_TEMPLATE converts between the google.protobuf messages and the
+// Kubernetes types native types use for them. nil converts to nil both ways.
+var WELL_KNOWN_CONVERT_TEMPLATE = `// Code generated by protoc-gen-k8s. DO NOT EDIT.
+
+package convert
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TimeToProto(in *metav1.Time) *timestamppb.Timestamp {
+	if in == nil {
+		return nil
+	}
+	return &timestamppb.Timestamp{Seconds: in.Unix(), Nanos: int32(in.Nanosecond())}
+}
+
+func TimeFromProto(in *timestamppb.Timestamp) *metav1.Time {
+	if in == nil {
+		return nil
+	}
+	out := metav1.NewTime(time.Unix(in.GetSeconds(), int64(in.GetNanos())).UTC())
+	return &out
+}
+
+func DurationToProto(in *metav1.Duration) *durationpb.Duration {
+	if in == nil {
+		return nil
+	}
+	return &durationpb.Duration{Seconds: int64(in.Duration / time.Second), Nanos: int32(in.Duration % time.Second)}
+}
+
+func DurationFromProto(in *durationpb.Duration) *metav1.Duration {
+	if in == nil {
+		return nil
+	}
+	return &metav1.Duration{Duration: time.Duration(in.GetSeconds())*time.Second + time.Duration(in.GetNanos())}
+}
+
+{{- range $_, $json := .JSON }}
+
+func {{ $json }}ToProto(in *apiextensionsv1beta1.JSON) (*structpb.{{ $json }}, error) {
+	if in == nil || len(in.Raw) == 0 {
+		return nil, nil
+	}
+	out := &structpb.{{ $json }}{}
+	if err := unmarshal(in.Raw, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func {{ $json }}FromProto(in *structpb.{{ $json }}) (*apiextensionsv1beta1.JSON, error) {
+	if in == nil {
+		return nil, nil
+	}
+	raw, err := marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	return &apiextensionsv1beta1.JSON{Raw: raw}, nil
+}
+{{- end }}
+
+// AnyToProto resolves the @type of the object among the registered messages
+func AnyToProto(in *runtime.RawExtension) (*anypb.Any, error) {
+	if in == nil || len(in.Raw) == 0 {
+		return nil, nil
+	}
+	out := &anypb.Any{}
+	if err := unmarshal(in.Raw, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func AnyFromProto(in *anypb.Any) (*runtime.RawExtension, error) {
+	if in == nil {
+		return nil, nil
+	}
+	raw, err := marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.RawExtension{Raw: raw}, nil
+}
+{{- range $_, $wrapper := .Wrappers }}
+{{ if $wrapper.Pointer }}
+func {{ $wrapper.Name }}ToProto(in *{{ $wrapper.GoType }}) *wrapperspb.{{ $wrapper.Name }} {
+	if in == nil {
+		return nil
+	}
+	return &wrapperspb.{{ $wrapper.Name }}{Value: *in}
+}
+
+func {{ $wrapper.Name }}FromProto(in *wrapperspb.{{ $wrapper.Name }}) *{{ $wrapper.GoType }} {
+	if in == nil {
+		return nil
+	}
+	out := in.GetValue()
+	return &out
+}
+{{- else }}
+func {{ $wrapper.Name }}ToProto(in {{ $wrapper.GoType }}) *wrapperspb.{{ $wrapper.Name }} {
+	if in == nil {
+		return nil
+	}
+	return &wrapperspb.{{ $wrapper.Name }}{Value: in}
+}
+
+func {{ $wrapper.Name }}FromProto(in *wrapperspb.{{ $wrapper.Name }}) {{ $wrapper.GoType }} {
+	return in.GetValue()
+}
+{{- end }}
+{{- end }}
+
+func marshal(in proto.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := (&jsonpb.Marshaler{}).Marshal(&buf, in); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshal(raw []byte, out proto.Message) error {
+	return jsonpb.Unmarshal(bytes.NewReader(raw), out)
+}
+`